@@ -0,0 +1,238 @@
+package astpos
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"strings"
+	"text/tabwriter"
+)
+
+// Computes the columns of the line comments of a parenthesized var or
+// const declaration the way go/printer aligns them, so that the
+// comments keep their column in the printed table (see WithColumns).
+// The columns are relative to the indentation of the specs.
+func (p *astPositioner) alignLineComments(decl *ast.GenDecl) {
+	var lines bytes.Buffer
+	var comments []*ast.CommentGroup
+	for i, spec := range decl.Specs {
+		spec, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			return
+		}
+		if i > 0 && decl.Tok == token.CONST && startsIotaSequence(spec) {
+			// An empty line ends the aligned section
+			lines.WriteString("\n")
+			comments = append(comments, nil)
+		}
+		lines.WriteString(valueSpecCells(spec))
+		lines.WriteString("\n")
+		comments = append(comments, spec.Comment)
+	}
+
+	// Same tabwriter configuration as go/printer
+	var out bytes.Buffer
+	w := tabwriter.NewWriter(&out, 0, 8, 1, ' ', tabwriter.DiscardEmptyColumns)
+	w.Write(lines.Bytes())
+	w.Flush()
+
+	for i, line := range strings.Split(out.String(), "\n") {
+		if i >= len(comments) || comments[i] == nil {
+			continue
+		}
+		if p.commentColumns == nil {
+			p.commentColumns = make(map[*ast.CommentGroup]int)
+		}
+		// The comment marker ends the line
+		p.commentColumns[comments[i]] = len(line) - 1
+	}
+}
+
+// Returns the tab separated cells of a value spec that go/printer
+// aligns, followed by a comment marker if the spec has a line comment
+func valueSpecCells(spec *ast.ValueSpec) string {
+	var cells strings.Builder
+	names := make([]string, len(spec.Names))
+	for i, name := range spec.Names {
+		names[i] = name.Name
+	}
+	cells.WriteString(strings.Join(names, ", "))
+	extraTabs := 3
+	if spec.Type != nil {
+		cells.WriteString("\v" + exprString(spec.Type))
+		extraTabs--
+	}
+	if len(spec.Values) > 0 {
+		values := make([]string, len(spec.Values))
+		for i, value := range spec.Values {
+			values[i] = exprString(value)
+		}
+		cells.WriteString("\v= " + strings.Join(values, ", "))
+		extraTabs--
+	}
+	if spec.Comment != nil {
+		cells.WriteString(strings.Repeat("\v", extraTabs) + "/")
+	}
+	return cells.String()
+}
+
+// Returns the expression printed on one line
+func exprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), expr)
+	return buf.String()
+}
+
+// Columns of the type and the tag of a struct field
+// relative to the indentation
+type fieldColumns struct {
+	typ, tag int
+}
+
+// Computes the columns of the types, tags and line comments of the
+// fields of a struct the way go/printer aligns them, so that they keep
+// their column in the printed table (see WithColumns). Must be called
+// after the fields have been reordered.
+func (p *astPositioner) alignFields(fields *ast.FieldList) {
+	var lines bytes.Buffer
+	var aligned []*ast.Field
+	for i, f := range fields.List {
+		if i > 0 && p.endsAlignedSection(fields.List[i-1], f) {
+			lines.WriteString("\n")
+			aligned = append(aligned, nil)
+		}
+		lines.WriteString(fieldCells(f))
+		lines.WriteString("\n")
+		aligned = append(aligned, f)
+	}
+
+	// Same tabwriter configuration as go/printer
+	var out bytes.Buffer
+	w := tabwriter.NewWriter(&out, 0, 8, 1, ' ', tabwriter.DiscardEmptyColumns)
+	w.Write(lines.Bytes())
+	w.Flush()
+
+	for i, line := range strings.Split(out.String(), "\n") {
+		if i >= len(aligned) || aligned[i] == nil {
+			continue
+		}
+		f := aligned[i]
+		var columns []int
+		column := 0
+		for _, cell := range strings.FieldsFunc(fieldCells(f), isCellTerminator) {
+			// Cells never start with a blank, so the padding
+			// ends at the first non-blank character
+			for column < len(line) && line[column] == ' ' {
+				column++
+			}
+			columns = append(columns, column)
+			column += len(cell)
+		}
+		if len(f.Names) == 0 {
+			// The type of an embedded field starts the line
+			columns = append([]int{0}, columns...)
+		}
+		if p.fieldColumns == nil {
+			p.fieldColumns = make(map[*ast.Field]fieldColumns)
+		}
+		cols := fieldColumns{typ: columns[1]}
+		next := 2
+		if isMultilineField(f) {
+			// The tag and the comment follow the last line
+			// of the type and are not aligned
+			p.fieldColumns[f] = cols
+			continue
+		}
+		if f.Tag != nil {
+			cols.tag = columns[next]
+			next++
+		}
+		p.fieldColumns[f] = cols
+		if f.Comment != nil {
+			if p.commentColumns == nil {
+				p.commentColumns = make(map[*ast.CommentGroup]int)
+			}
+			p.commentColumns[f.Comment] = columns[next]
+		}
+	}
+}
+
+// Reports whether go/printer starts a new aligned section between
+// two struct fields. Comment lines, empty lines and the end of a
+// multiline field end a section.
+func (p *astPositioner) endsAlignedSection(prev, f *ast.Field) bool {
+	if order := p.opts.FieldOrder; order != nil && order(prev, f) != 0 {
+		return true
+	}
+	return f.Doc != nil || p.opts.BlankLinesAfter[prev] || isMultilineField(prev) ||
+		len(p.floating.before[f]) > 0 || len(p.floating.after[prev]) > 0
+}
+
+// Returns the cells of a struct field that go/printer aligns,
+// separated by tabs. The comment is represented by its marker.
+func fieldCells(f *ast.Field) string {
+	var cells strings.Builder
+	extraTabs := 2
+	if len(f.Names) > 0 {
+		names := make([]string, len(f.Names))
+		for i, name := range f.Names {
+			names[i] = name.Name
+		}
+		cells.WriteString(strings.Join(names, ", ") + "\v")
+		extraTabs--
+	}
+	typ := exprString(f.Type)
+	if isMultilineField(f) {
+		// Only the first line of a multiline
+		// field takes part in the alignment
+		first, _, _ := strings.Cut(typ, "\n")
+		cells.WriteString(first)
+		return cells.String()
+	}
+	cells.WriteString(typ)
+	if f.Tag != nil {
+		if len(f.Names) > 0 {
+			cells.WriteString("\v")
+		}
+		cells.WriteString("\v" + f.Tag.Value)
+		extraTabs = 0
+	}
+	if f.Comment != nil {
+		if extraTabs == 0 {
+			// A comment that does not follow a
+			// vertical tab is separated by a tab
+			cells.WriteString("\t/")
+		} else {
+			cells.WriteString(strings.Repeat("\v", extraTabs) + "/")
+		}
+	}
+	return cells.String()
+}
+
+func isCellTerminator(r rune) bool {
+	return r == '\v' || r == '\t'
+}
+
+// Reports whether the struct field spans several lines. Nested
+// structs are never kept on one line by the positioner.
+func isMultilineField(f *ast.Field) bool {
+	if f.Tag != nil && strings.Contains(f.Tag.Value, "\n") {
+		return true
+	}
+	multiline := false
+	ast.Inspect(f.Type, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncType:
+			// Structs in parameters may be kept on one line
+			multiline = multiline || strings.Contains(exprString(n), "\n")
+			return false
+		case *ast.StructType:
+			multiline = multiline || len(n.Fields.List) > 0
+		case *ast.InterfaceType:
+			multiline = multiline || !isOneLineFieldList(n.Methods)
+		}
+		return !multiline
+	})
+	return multiline
+}
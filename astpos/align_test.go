@@ -0,0 +1,78 @@
+package astpos
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestAlignedLineComments(t *testing.T) {
+	src := `package astpos
+
+const (
+	A      = iota         // a
+	Bbbbbb                // b
+	C      = "long value" // c
+
+	D = iota * 2 // d
+	E            // e
+)
+
+var (
+	x, y int    = 1, 2 // x and y
+	z    string        // z
+)
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []token.Position
+	for _, c := range f.Comments {
+		want = append(want, fset.Position(c.Pos()))
+	}
+
+	f, fset = RewritePositions(f, WithColumns())
+	for i, c := range f.Comments {
+		if got := fset.Position(c.Pos()); got.Column != want[i].Column {
+			t.Errorf("Comment %s at column %d, expected %d", c.Text(), got.Column, want[i].Column)
+		}
+	}
+	checkResult(t, writeAST(t, f, fset), src)
+}
+
+func TestAlignedFields(t *testing.T) {
+	src := `package astpos
+
+type T struct {
+	A int ` + "`json:\"a\"`" + ` // a
+	// Doc
+	Bcdef    string ` + "`json:\"b\"`" + `
+	Embedded ` + "`json:\"e\"`" + `
+	X        struct {
+		Y int
+	} ` + "`t:\"x\"`" + ` // x
+	Z            int ` + "`json:\"z\"`" + `
+	Long         int // long
+	LongLongName int
+	E                                  // e
+	F, G         map[string]int        ` + "`json:\"fg\" xml:\"fg\"`" + `
+	H            func(struct{ a int }) ` + "`json:\"h\"`" + `
+}
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := nodeColumns(f, fset)
+
+	f, fset = RewritePositions(f, WithColumns())
+	if result := nodeColumns(f, fset); result != expected {
+		t.Fatalf("Columns differ from the parsed source:\n%s\nexpected:\n%s", result, expected)
+	}
+	checkResult(t, writeAST(t, f, fset), src)
+}
@@ -0,0 +1,70 @@
+package astpos
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+)
+
+// Returns true if the struct type is kept on one line like
+// struct{ A int }. Anonymous structs outside of other structs are
+// kept on one line (see ProfileV2) if go/printer allows it,
+// empty structs always.
+func (p *astPositioner) isInlineStruct(n *ast.StructType) bool {
+	if n.Fields == nil || len(n.Fields.List) == 0 {
+		return true
+	}
+	if !p.opts.layout().inlineStructs || n == p.declaredStruct || p.hasHint(n, HintExpand) {
+		return false
+	}
+	return p.context() != ContextStruct && isOneLineFieldList(n.Fields)
+}
+
+// Returns true if go/printer prints the fields of a struct or
+// interface type on one line when they are positioned on one line:
+// a single field without tag and comments whose names and type
+// are short enough
+func isOneLineFieldList(fields *ast.FieldList) bool {
+	if fields == nil || len(fields.List) != 1 {
+		return false
+	}
+	f := fields.List[0]
+	if f.Tag != nil || f.Doc != nil || f.Comment != nil {
+		return false
+	}
+
+	// The same approximation as in go/printer
+	const maxSize = 30
+	size := 0
+	for i, name := range f.Names {
+		if i > 0 {
+			size += len(", ")
+		}
+		size += len(name.Name)
+	}
+	if size > maxSize {
+		return false
+	}
+	if size > 0 {
+		size = 1
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, token.NewFileSet(), f.Type); err != nil {
+		return false
+	}
+	return bytes.IndexByte(buf.Bytes(), '\n') < 0 && size+buf.Len() <= maxSize
+}
+
+// Positions the fields of a struct type on one line
+func (p *astPositioner) inlineFields(fields *ast.FieldList) {
+	fields.Opening = p.pc()
+	p.move(token.LBRACE)
+	if len(fields.List) > 0 {
+		p.space()
+		traverseListSep(p, fields.List, p.semicolon)
+		p.space()
+	}
+	fields.Closing = p.pc()
+	p.move(token.RBRACE)
+}
@@ -0,0 +1,58 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestAnonymousTypes(t *testing.T) {
+	src := `package astpos
+
+var i interface{ Foo() }
+
+func g[T interface{ ~int | ~string }](x struct{ a int }) {
+	v := struct{ A int }{A: 1}
+	w := struct {
+		A int
+		B int
+	}{}
+	e := struct{}{}
+	_ = []struct{ a, b int }{}
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := nodeColumns(f, fset)
+
+	f, fset = RewritePositions(f, WithColumns())
+	if result := nodeColumns(f, fset); result != expected {
+		t.Fatalf("Columns differ from the parsed source:\n%s\nexpected:\n%s", result, expected)
+	}
+	checkResult(t, writeAST(t, f, fset), src)
+}
+
+func TestAnonymousTypesProfileV1(t *testing.T) {
+	src := `package astpos
+
+	var v = struct{ A int }{A: 1}
+	`
+	expected := `package astpos
+
+var v = struct {
+	A int
+}{A: 1}
+`
+	f, fset := RewritePositions(parseSource(t, src), WithProfile(ProfileV1))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	// Expanded by a hint in the latest profile
+	f = parseSource(t, src)
+	lit := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0].(*ast.CompositeLit)
+	f, fset = RewritePositions(f, WithHints(Hints{lit.Type: HintExpand}))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
@@ -1,9 +1,14 @@
 package astpos
 
 import (
+	"bytes"
+	"fmt"
 	"go/ast"
+	"go/printer"
 	"go/token"
 	"reflect"
+	"slices"
+	"strings"
 )
 
 // Rewrites the position values of all AST nodes in the given file.
@@ -12,75 +17,397 @@ import (
 // for go/format or go/print.
 //
 // Supports doc comments on the lines directly above the
-// following: Top of the file, import/const/type/var declarations,
-// function declarations and struct fields.
-// Block comments (/**/), end of line comments and free floating
-// comments will be misplaced when printing the AST but the
-// node positions could be used to correct this to some degree
+// following: Top of the file, import/const/type/var declarations
+// and their specs, function declarations and struct fields.
+// End of line comments are supported on the package clause, struct
+// fields, import specs and const/var specs.
+// Free floating comments and other end of line comments are only
+// kept if the original FileSet is given (see WithOriginalFileSet).
+// Block comments (/**/) may span multiple lines in all of these places.
+// Block comments within expressions will be misplaced when printing the
+// AST but the node positions could be used to correct this to some degree
 // (see https://github.com/golang/go/issues/18593#issuecomment-295916961).
 //
 // Adds linebreaks to block-statements/-declarations and the doc
 // comments. All other linebreaks should be adequately inserted by
 // the formatting of go/format.
-func RewritePositions(f *ast.File) (*ast.File, *token.FileSet) {
-	p := newPositioner(f)
+//
+// The behaviour can be adjusted with Options (see the With... functions).
+func RewritePositions(f *ast.File, opts ...Option) (*ast.File, *token.FileSet) {
+	return RewritePositionsWithOptions(f, newOptions(opts))
+}
+
+// Same as RewritePositions but configured by an Options struct
+func RewritePositionsWithOptions(f *ast.File, opts Options) (*ast.File, *token.FileSet) {
+	fset := token.NewFileSet()
+	p := newPositioner(f, fset, opts)
+	p.positionTokens()
+	return f, fset
+}
+
+// Same as RewritePositions but the file is added to the given
+// FileSet instead of a new one. This way multiple rewritten files
+// can be formatted with one FileSet and their positions compared.
+// Returns the added *token.File.
+func RewritePositionsInto(fset *token.FileSet, f *ast.File, opts ...Option) *token.File {
+	p := newPositioner(f, fset, newOptions(opts))
+	p.positionTokens()
+	return p.File
+}
+
+// Rewrites the positions of all files of a package into one shared
+// FileSet. The files are keyed by their file names, which are used
+// as the names in the FileSet, and added in the order of their names.
+// Returns an error if the files do not share the same package name.
+func RewritePackage(files map[string]*ast.File, opts ...Option) (*token.FileSet, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	pkgName := ""
+	for _, name := range names {
+		f := files[name]
+		if f == nil || f.Name == nil {
+			return nil, fmt.Errorf("astpos: file %s has no package name", name)
+		}
+		if pkgName == "" {
+			pkgName = f.Name.Name
+		} else if f.Name.Name != pkgName {
+			return nil, fmt.Errorf("astpos: file %s is in package %s instead of %s", name, f.Name.Name, pkgName)
+		}
+	}
+
+	fset := token.NewFileSet()
+	options := newOptions(opts)
+	for _, name := range names {
+		options.Filename = name
+		p := newPositioner(files[name], fset, options)
+		p.positionTokens()
+	}
+	return fset, nil
+}
+
+// Rewrites the position values of the given node and all of its
+// children, e.g. a synthesized *ast.FuncDecl, *ast.BlockStmt or
+// a single expression that is printed without a surrounding file.
+// The returned ast.Node is the same as the given one and the newly
+// created *token.FileSet can be used to print it with go/printer.
+//
+// If the node is an *ast.File this is the same as RewritePositions.
+// Otherwise only the doc comments of the nodes are positioned.
+func RewriteNode(n ast.Node, opts ...Option) (ast.Node, *token.FileSet) {
+	fset := token.NewFileSet()
+	p := newPositioner(n, fset, newOptions(opts))
 	p.positionTokens()
-	return f, p.fset
+	return n, fset
 }
 
 type astPositioner struct {
-	root *ast.File
+	root ast.Node
+	// The root if it is a file, otherwise nil
+	file *ast.File
 	*token.File
 
+	// Temporary file of the maximum size that the positions are
+	// counted in (see shrinkFile)
+	tmpFile *token.File
+
+	// The FileSet that receives the file after positioning
 	fset *token.FileSet
 
 	// Position counter
 	p int
 
+	// Indentation depth of the current line and whether it still has
+	// to be added in front of the next token (see WithColumns)
+	indent        int
+	indentPending bool
+	// Position after the last space
+	spaced int
+
 	listSizeStack, listIndexStack []int
 
-	inStruct bool
+	// Depths of the list stack at which composite literal elements are traversed
+	eltsDepthStack []int
+
+	// Kinds of the field lists around the current node,
+	// the innermost last
+	contextStack []Context
+
+	// Function type whose func keyword is not printed (interface
+	// methods) or positioned already (function declarations)
+	keywordless *ast.FuncType
+
+	// Composite literals used as map keys, small composite literals
+	// in gofumpt mode and nodes with HintOneLine are kept on one line
+	oneLine bool
+
+	// Block that is followed by more tokens on the
+	// line of its closing brace, e.g. by else
+	continuedBlock *ast.BlockStmt
+
+	// Body of the function whose signature was positioned last
+	funcBody *ast.BlockStmt
+
+	// Whether funcBody is kept on one line (see WithCompactBodies)
+	isCompact bool
+
+	// Concatenations of the parts of split string literals
+	// (see WithMaxStringWidth)
+	splitLits map[ast.Node]bool
+
+	// Memoized lower bounds of the single line widths of expressions
+	// and nodes known to fit on their line (see exceedsLineWidth)
+	flatWidths map[ast.Node]int
+	fitting    map[ast.Node]bool
+
+	// Nodes whose invariants are checked after their
+	// children (see WithInvariantChecks)
+	invariantStack []invariantFrame
+
+	// A composite literal element ended and its line
+	// ends after the following comma
+	eltLine bool
+
+	// Interface type of the current type declaration, whose
+	// elements are laid out one per line
+	declaredInterface *ast.InterfaceType
+
+	// Struct type of the current type declaration,
+	// which is never kept on one line
+	declaredStruct *ast.StructType
+
+	// Fields of the declared struct type that are reordered
+	// (see WithFieldOrder)
+	orderedFields *ast.FieldList
 
 	comments []*ast.CommentGroup
+
+	// Columns of aligned line comments relative to the indentation
+	commentColumns map[*ast.CommentGroup]int
+
+	// Columns of the types and tags of aligned struct fields
+	fieldColumns map[*ast.Field]fieldColumns
+
+	// Doc comment of a spec that was already positioned
+	// in front of its declaration keyword
+	hoistedDoc *ast.CommentGroup
+
+	// Comment on the same line as the package clause
+	packageComment *ast.CommentGroup
+
+	// Comments above the package doc, e.g. build constraints
+	headerComments []*ast.CommentGroup
+
+	floating floatingComments
+
+	// Nodes whose hints are being applied
+	hinted map[ast.Node]bool
+
+	// Top-level declarations that keep their original layout
+	unchanged map[ast.Node]unchangedDecl
+
+	// Method value of down, which is created once instead of
+	// on every call of ast.Inspect
+	downFunc func(ast.Node) bool
+
+	opts Options
 }
 
-func newPositioner(root *ast.File) *astPositioner {
-	fset := token.NewFileSet()
-	maxInt := int(^uint(0) >> 1)
-	file := fset.AddFile("x.go", 1, maxInt-2)
+func newPositioner(root ast.Node, fset *token.FileSet, opts Options) *astPositioner {
+	p := &astPositioner{opts: opts}
+	p.init(root, fset)
+	return p
+}
 
-	positioner := &astPositioner{
-		root:           root,
-		File:           file,
-		fset:           fset,
-		p:              1,
-		listSizeStack:  make([]int, 0),
-		listIndexStack: make([]int, 0),
-		comments:       make([]*ast.CommentGroup, 0),
+// Prepares the positioner for the rewrite of the root node into the FileSet
+func (p *astPositioner) init(root ast.Node, fset *token.FileSet) {
+	p.reset()
+
+	// The size of the file is unknown until all positions are set.
+	// Until then the file is kept in a temporary FileSet.
+	// It is reused by the next rewrite if the base matches.
+	base := fset.Base()
+	if p.tmpFile == nil || p.tmpFile.Base() != base {
+		maxInt := int(^uint(0) >> 1)
+		p.tmpFile = token.NewFileSet().AddFile(p.opts.filename(), base, maxInt-base-1)
+	} else {
+		p.tmpFile.SetLines([]int{0})
 	}
 
-	return positioner
+	p.root = root
+	p.file, _ = root.(*ast.File)
+	if p.opts.MaxStringWidth > 0 {
+		p.splitStrings(root)
+	}
+	p.File = p.tmpFile
+	p.fset = fset
+	p.p = base
+	if p.file != nil {
+		// The comment groups end up in the file, so their
+		// slice can not be reused by the next rewrite
+		p.comments = make([]*ast.CommentGroup, 0, len(p.file.Comments))
+	}
+}
+
+// Drops all state of the last rewrite but keeps the options,
+// the temporary file and the buffers of the list stacks
+func (p *astPositioner) reset() {
+	*p = astPositioner{
+		listSizeStack:  p.listSizeStack[:0],
+		listIndexStack: p.listIndexStack[:0],
+		eltsDepthStack: p.eltsDepthStack[:0],
+		contextStack:   p.contextStack[:0],
+		tmpFile:        p.tmpFile,
+		downFunc:       p.downFunc,
+		opts:           p.opts,
+	}
+	if p.downFunc == nil {
+		p.downFunc = p.down
+		if p.opts.CheckInvariants {
+			p.downFunc = p.checkedDown
+		}
+	}
 }
 
 func (p *astPositioner) positionTokens() {
-	p.root.FileStart = 1
-	p.traverse(p.root)
-	p.root.FileEnd = p.pc()
-	p.root.Comments = p.comments
+	if p.file == nil {
+		p.traverse(p.root)
+		p.shrinkFile()
+		return
+	}
+	if anchors := p.opts.CommentAnchors; anchors != nil {
+		p.packageComment = anchors.packageComment
+		p.headerComments = anchors.headerComments
+		p.floating = anchors.floating
+	} else {
+		p.packageComment = findPackageComment(p.file, p.opts.OriginalFileSet)
+		p.headerComments = findHeaderComments(p.file)
+	}
+	if p.opts.PreserveUnchanged && p.opts.OriginalFileSet != nil {
+		p.collectUnchangedDecls()
+	}
+	if p.opts.OriginalFileSet != nil && p.opts.CommentAnchors == nil {
+		p.collectFloatingComments()
+	}
+	p.file.FileStart = p.pc()
+	p.traverse(p.file)
+	p.file.FileEnd = p.pc()
+	p.file.Comments = p.comments
+	p.shrinkFile()
+}
+
+// Replaces the temporary file, which had to be created with a
+// maximum size before positioning, with a file of the actual size
+// in the target FileSet.
+// Otherwise the FileSet could not take any further files, which
+// go/format needs when it re-parses a file to sort its imports.
+func (p *astPositioner) shrinkFile() {
+	file := p.fset.AddFile(p.File.Name(), p.File.Base(), p.p-p.File.Base()+1)
+	file.SetLines(p.File.Lines())
+	p.File = file
 }
 
-// Returns the current position counter
+// Returns the current position counter.
+// The indentation of a new line is added in front of its first token,
+// so that it always matches the depth of the token.
 func (p *astPositioner) pc() token.Pos {
+	if p.indentPending {
+		p.indentPending = false
+		p.moveN(p.indent)
+	}
 	return token.Pos(p.p)
 }
 
 func (p *astPositioner) newline() {
-	p.AddLine(p.p)
 	p.moveN(1)
+	p.AddLine(p.p - p.Base())
+	p.indentPending = p.opts.Columns
+}
+
+// Returns true if no token was positioned on the current line yet
+func (p *astPositioner) atLineStart() bool {
+	pos := token.Pos(p.p)
+	return p.LineStart(p.Line(pos)) == pos
+}
+
+// Separates the next token from the previous one by a space
+// unless it is already separated (see WithColumns)
+func (p *astPositioner) space() {
+	if p.opts.Columns && !p.atLineStart() && p.spaced != p.p {
+		p.moveN(1)
+		p.spaced = p.p
+	}
+}
+
+// Starts a new line unless the current position is at the start of one
+func (p *astPositioner) endLine() {
+	if !p.atLineStart() {
+		p.newline()
+	}
+}
+
+// Gives the field list of a generated signature valid parenthesis
+// positions if go/printer prints it in parentheses, so that they are
+// positioned with the list
+func markParens(fields *ast.FieldList, parens bool) {
+	if fields != nil && parens && !fields.Opening.IsValid() {
+		fields.Opening, fields.Closing = 1, 1
+	}
+}
+
+// Moves to the column relative to the indentation, or past a
+// space if the current position is not in front of it
+func (p *astPositioner) alignTo(column int) {
+	if start := int(p.LineStart(p.Line(p.pc()))); column > 0 && start+p.indent+column > p.p {
+		p.p = start + p.indent + column
+		return
+	}
+	p.space()
+}
+
+// Makes sure that the current position is preceded by an empty line
+func (p *astPositioner) blankLine() {
+	if !p.atLineStart() {
+		p.newline()
+	}
+	line := p.Line(p.pc())
+	if line < 2 || p.LineStart(line)-p.LineStart(line-1) > 1 {
+		p.newline()
+	}
+}
+
+// Separates the top-level declaration at the given index
+// from the previous one according to the blank line policy
+func (p *astPositioner) separateDecl(index int) {
+	if p.file == nil || p.opts.BlankLines == BlankLinesDefault {
+		return
+	}
+	p.endLine()
+	switch p.opts.BlankLines {
+	case BlankLinesAll:
+		p.blankLine()
+	case BlankLinesGrouped:
+		if !isDeclGroup(p.file.Decls[index-1], p.file.Decls[index]) {
+			p.blankLine()
+		}
+	}
 }
 
 func (p *astPositioner) move(t token.Token) {
-	p.p += len(t.String())
+	p.moveToken(len(t.String()))
+}
+
+// Moves past a token of the given length and,
+// with TokenSpacing, past a space behind it
+func (p *astPositioner) moveToken(n int) {
+	p.p += n
+	if p.opts.TokenSpacing {
+		p.p++
+		p.spaced = p.p
+	}
 }
 
 func (p *astPositioner) moveStr(s string) {
@@ -91,26 +418,109 @@ func (p *astPositioner) moveN(n int) {
 	p.p += n
 }
 
+// Moves past the text and starts a new line
+// for each line break within it (e.g. in /* */ comments)
+func (p *astPositioner) moveText(s string) {
+	for {
+		line, rest, found := strings.Cut(s, "\n")
+		p.moveStr(line)
+		if !found {
+			return
+		}
+		p.newline()
+		s = rest
+	}
+}
+
+// Moves past the literal and starts a new line for each line break
+// within it (i.e. in raw strings). Unlike moveText the lines are not
+// indented, since they are part of the literal.
+func (p *astPositioner) moveLit(s string) {
+	start := p.p
+	for i := range len(s) {
+		if s[i] == '\n' {
+			p.AddLine(start + i + 1 - p.Base())
+		}
+	}
+	p.moveToken(len(s))
+}
+
 func (p *astPositioner) traverse(node ast.Node) {
 	if node == nil {
 		return
 	}
-	ast.Inspect(node, p.down)
+	ast.Inspect(node, p.downFunc)
 }
 
 func traverseList[Slice ~[]E, E ast.Node](p *astPositioner, nodes Slice) {
+	traverseListSep(p, nodes, nil)
+}
+
+// Same as traverseList but calls sep after each element
+func traverseListSep[Slice ~[]E, E ast.Node](p *astPositioner, nodes Slice, sep func()) {
 	// Cannot be a method because of the type params
 	p.listSizeStack = append(p.listSizeStack, len(nodes))
 	p.listIndexStack = append(p.listIndexStack, 0)
 	i := len(p.listSizeStack) - 1
 	for _, n := range nodes {
+		if _, ok := any(n).(ast.Decl); ok && i == 0 && p.listIndexStack[i] > 0 {
+			p.separateDecl(p.listIndexStack[i])
+		}
+		p.handleFloatingBefore(n)
 		p.traverse(n)
+		p.handleFloatingAfter(n)
+		if sep != nil {
+			sep()
+		}
+		p.separateParagraph(n)
 		p.listIndexStack[i] += 1
 	}
 	p.listSizeStack = p.listSizeStack[:i]
 	p.listIndexStack = p.listIndexStack[:i]
 }
 
+// Moves past the comma between two elements of a list
+func (p *astPositioner) comma() {
+	if p.index() < p.listSize()-1 {
+		p.move(token.COMMA)
+		p.space()
+	}
+}
+
+// Moves past the comma after an element of a list
+// and ends the line
+func (p *astPositioner) commaLine() {
+	p.move(token.COMMA)
+	p.endLine()
+}
+
+// Moves past the semicolon between two elements of a list,
+// e.g. the methods of a single line interface
+func (p *astPositioner) semicolon() {
+	if p.index() < p.listSize()-1 {
+		p.move(token.SEMICOLON)
+		p.space()
+	}
+}
+
+// Same as comma for the elements of a composite literal, but ends
+// the line after elements that are composite literals themselves
+func (p *astPositioner) eltComma() {
+	if p.eltLine {
+		p.eltLine = false
+		p.move(token.COMMA)
+		p.newline()
+		return
+	}
+	p.comma()
+}
+
+// Same as commaLine for the elements of a composite literal
+func (p *astPositioner) eltCommaLine() {
+	p.eltLine = false
+	p.commaLine()
+}
+
 // Returns the size of the list that is being traversed
 // -1 if not inside a list
 func (p *astPositioner) listSize() int {
@@ -129,6 +539,15 @@ func (p *astPositioner) index() int {
 	return p.listIndexStack[len(p.listIndexStack)-1]
 }
 
+// Returns true if the list that is being traversed
+// holds the elements of a composite literal
+func (p *astPositioner) inCompositeElts() bool {
+	if len(p.eltsDepthStack) == 0 {
+		return false
+	}
+	return p.eltsDepthStack[len(p.eltsDepthStack)-1] == len(p.listSizeStack)-1
+}
+
 // Sets the position fields of the encountered node type
 // and moves the position counter up accordingly.
 //
@@ -144,7 +563,14 @@ func (p *astPositioner) down(n ast.Node) bool {
 	if n == nil {
 		return false
 	}
-	if v := reflect.ValueOf(n); v.Kind() == reflect.Ptr && v.IsNil() {
+	if isNil(n) {
+		return false
+	}
+	if u, ok := p.unchanged[n]; ok {
+		p.copyDecl(n, u)
+		return false
+	}
+	if p.applyHints(n) || p.handleNode(n) {
 		return false
 	}
 	pc := p.pc
@@ -158,46 +584,91 @@ func (p *astPositioner) down(n ast.Node) bool {
 		return false
 
 	case *ast.AssignStmt:
-		traverseList(p, n.Lhs)
+		traverseListSep(p, n.Lhs, p.comma)
+		p.space()
 		n.TokPos = pc()
 		p.move(n.Tok)
-		traverseList(p, n.Rhs)
+		p.space()
+		traverseListSep(p, n.Rhs, p.comma)
 		return false
 
+	case *ast.BadDecl:
+		p.badNode(&n.From, &n.To, "BadDecl")
+
+	case *ast.BadExpr:
+		p.badNode(&n.From, &n.To, "BadExpr")
+
+	case *ast.BadStmt:
+		p.badNode(&n.From, &n.To, "BadStmt")
+
 	case *ast.BasicLit:
+		if p.opts.BasicLitRewriter != nil {
+			p.opts.BasicLitRewriter(n)
+		}
 		n.ValuePos = pc()
-		p.moveStr(n.Value)
+		p.moveLit(n.Value)
+		setValueEnd(n, n.ValuePos+token.Pos(len(n.Value)))
 
 	case *ast.BinaryExpr:
+		if p.splitLits[n] || p.hasHint(n, HintExpand) || p.exceedsLineWidth(n) {
+			p.wrappedChain(n)
+			return false
+		}
 		p.traverse(n.X)
+		p.space()
 		n.OpPos = pc()
 		p.move(n.Op)
+		p.space()
 		p.traverse(n.Y)
 		return false
 
 	case *ast.BlockStmt:
+		// The clauses of switch and select statements are not indented
+		indent := 1
+		if len(n.List) > 0 && isClause(n.List[0]) {
+			indent = 0
+		}
+		continued := n == p.continuedBlock
+		funcBody := n == p.funcBody
+		p.space()
 		n.Lbrace = pc()
 		p.move(token.LBRACE)
-		p.newline()
-		traverseList(p, n.List)
+		switch {
+		case funcBody && p.opts.CompactEmptyBodies && len(n.List) == 0 && len(p.floating.end[n]) == 0:
+		case funcBody && p.isCompact:
+			p.isCompact = false
+			p.compactBody(n)
+		default:
+			p.newline()
+			p.indent += indent
+			// One statement per line as printed by go/printer
+			traverseListSep(p, n.List, p.endLine)
+			p.handleFloatingEnd(n)
+			p.indent -= indent
+		}
 		n.Rbrace = pc()
 		p.move(token.RBRACE)
-		p.newline()
+		if !continued {
+			p.newline()
+		}
 		return false
 
 	case *ast.BranchStmt:
 		n.TokPos = pc()
 		p.move(n.Tok)
+		if n.Label != nil {
+			p.space()
+		}
 
 	case *ast.CallExpr:
-		p.traverse(n.Fun)
-		n.Lparen = pc()
-		traverseList(p, n.Args)
-		if n.Ellipsis != token.NoPos {
-			n.Ellipsis = pc()
-			p.move(token.ELLIPSIS)
+		if links := p.callChain(n); links != nil {
+			p.wrappedCallChain(links)
+			return false
 		}
-		n.Rparen = pc()
+		// Measured from the start of the call, not from the parenthesis
+		wrap := p.hasHint(n, HintExpand) || p.exceedsLineWidth(n)
+		p.traverse(n.Fun)
+		p.callArgs(n, wrap)
 		return false
 
 	case *ast.CaseClause:
@@ -206,12 +677,16 @@ func (p *astPositioner) down(n ast.Node) bool {
 			p.move(token.DEFAULT)
 		} else {
 			p.move(token.CASE)
+			p.space()
 		}
-		traverseList(p, n.List)
+		traverseListSep(p, n.List, p.comma)
 		n.Colon = pc()
 		p.move(token.COLON)
 		p.newline()
-		traverseList(p, n.Body)
+		p.indent++
+		traverseListSep(p, n.Body, p.endLine)
+		p.handleFloatingEnd(n)
+		p.indent--
 		return false
 
 	case *ast.ChanType:
@@ -226,6 +701,7 @@ func (p *astPositioner) down(n ast.Node) bool {
 			n.Arrow = pc()
 			p.move(token.ARROW)
 		}
+		p.space()
 
 	case *ast.CommClause:
 		n.Case = pc()
@@ -233,43 +709,80 @@ func (p *astPositioner) down(n ast.Node) bool {
 			p.move(token.DEFAULT)
 		} else {
 			p.move(token.CASE)
+			p.space()
 		}
 		p.traverse(n.Comm)
 		n.Colon = pc()
 		p.move(token.COLON)
 		p.newline()
-		traverseList(p, n.Body)
+		p.indent++
+		traverseListSep(p, n.Body, p.endLine)
+		p.handleFloatingEnd(n)
+		p.indent--
+		// The next case starts on a new line,
+		// also if the body is empty
+		p.endLine()
 		return false
 
 	// Comments handled separately
 
 	case *ast.CompositeLit:
+		if p.opts.SortMapKeys {
+			sortMapKeys(n, n.Type)
+		}
 		hasComposites := hasNestedComposite(n)
 		hasKeyValues := hasNestedKeyValue(n)
-		isMulti := len(n.Elts) >= 4
+		isMulti := p.opts.isMultiline(len(n.Elts))
 		isSingle := len(n.Elts) == 1
-		doNewlines := hasComposites || (hasKeyValues && !isSingle) || isMulti
+		isSmall := p.opts.Gofumpt && !hasComposites && !isMulti
+
+		layout := LiteralLayoutDefault
+		if p.opts.CompositeLitLayout != nil {
+			layout = p.opts.CompositeLitLayout(n)
+		}
+		if p.hasHint(n, HintExpand) {
+			layout = LiteralLayoutOnePerLine
+		} else if p.hasHint(n, HintOneLine) {
+			layout = LiteralLayoutInline
+		}
+		isInline := isSmall || layout == LiteralLayoutInline
+		isOnePerLine := layout == LiteralLayoutOnePerLine && len(n.Elts) > 0
+		doNewlines := isOnePerLine || (!p.oneLine && !isInline && (hasComposites || (hasKeyValues && !isSingle) || isMulti))
 
 		p.traverse(n.Type)
 		n.Lbrace = pc()
 		p.move(token.LBRACE)
 		if doNewlines {
 			p.newline()
+			p.indent++
+		}
+		p.eltsDepthStack = append(p.eltsDepthStack, len(p.listSizeStack))
+		oneLine := p.oneLine
+		p.oneLine = (oneLine && !isOnePerLine) || isInline
+		if isOnePerLine {
+			traverseListSep(p, n.Elts, p.eltCommaLine)
+		} else {
+			traverseListSep(p, n.Elts, p.eltComma)
 		}
-		traverseList(p, n.Elts)
+		p.oneLine = oneLine
+		p.eltsDepthStack = p.eltsDepthStack[:len(p.eltsDepthStack)-1]
 		if doNewlines {
 			p.newline()
+			p.indent--
 		}
 		n.Rbrace = pc()
 		p.move(token.RBRACE)
-		if isMulti || p.listSize() > 0 {
-			p.newline()
+		if p.inCompositeElts() && !p.oneLine {
+			// One element per line in the surrounding composite,
+			// the line ends after the comma (see eltComma)
+			p.eltLine = true
 		}
 		return false
 
 	case *ast.DeferStmt:
 		n.Defer = pc()
 		p.move(token.DEFER)
+		p.space()
 
 	case *ast.Ellipsis:
 		n.Ellipsis = pc()
@@ -283,20 +796,56 @@ func (p *astPositioner) down(n ast.Node) bool {
 
 	case *ast.Field:
 		p.handleComment(n.Doc)
+		traverseListSep(p, n.Names, p.comma)
+		columns := p.fieldColumns[n]
+		if method, ok := n.Type.(*ast.FuncType); ok && p.context() == ContextInterface && len(n.Names) > 0 {
+			// Interface methods have no func keyword
+			p.keywordless = method
+		} else if len(n.Names) > 0 {
+			p.alignTo(columns.typ)
+		}
+		p.traverse(n.Type)
+		if n.Tag != nil {
+			p.alignTo(columns.tag)
+			p.traverse(n.Tag)
+		}
+		p.handleLineComment(n.Comment)
+		return false
 
 	case *ast.FieldList:
+		inStruct := p.context() == ContextStruct
 		if n.Opening != token.NoPos {
 			n.Opening = pc()
-			p.moveN(1)
-			if p.inStruct {
+			p.moveToken(1)
+			if inStruct {
 				p.newline()
+				p.indent++
 			}
 		}
-		traverseList(p, n.List)
+		if inStruct {
+			// One field per line
+			sep := p.endLine
+			if n == p.orderedFields {
+				sep = p.groupFields(n)
+			}
+			if p.opts.Columns && len(n.List) > 1 {
+				p.alignFields(n)
+			}
+			traverseListSep(p, n.List, sep)
+		} else {
+			traverseListSep(p, n.List, p.comma)
+		}
+		p.handleFloatingEnd(n)
 		if n.Closing != token.NoPos {
+			if inStruct {
+				p.indent--
+			}
 			n.Closing = pc()
-			p.moveN(1)
-			if p.inStruct {
+			p.moveToken(1)
+			if inStruct && len(p.contextStack) == 1 {
+				// Blank line after the outermost struct. Nested
+				// structs are followed by the tag or line comment
+				// of their field.
 				p.newline()
 				p.newline()
 			}
@@ -304,45 +853,167 @@ func (p *astPositioner) down(n ast.Node) bool {
 		return false
 
 	case *ast.File:
+		for _, c := range p.headerComments {
+			// Build constraints must be followed by an empty line
+			// to not become part of the package doc
+			p.handleComment(c)
+			p.newline()
+		}
 		p.handleComment(n.Doc)
 		n.Package = pc()
 		p.move(token.PACKAGE)
-		p.moveStr(" ")
+		if !p.opts.TokenSpacing {
+			p.moveStr(" ")
+		}
 		p.traverse(n.Name)
-		p.newline()
-		traverseList(p, n.Decls)
+		if p.packageComment != nil {
+			p.handleLineComment(p.packageComment)
+			// go/printer only separates the declarations from the
+			// package clause by itself if no comment comes between
+			if len(n.Decls) > 0 {
+				p.newline()
+			}
+		} else {
+			p.newline()
+		}
+		// One declaration per line
+		traverseListSep(p, n.Decls, p.endLine)
+		p.handleFloatingEnd(n)
 		return false
 
 	case *ast.ForStmt:
 		n.For = pc()
 		p.move(token.FOR)
+		p.space()
+		if n.Init == nil && n.Post == nil {
+			// for {} or for cond {}
+			p.traverse(n.Cond)
+		} else {
+			p.traverse(n.Init)
+			p.move(token.SEMICOLON)
+			p.space()
+			p.traverse(n.Cond)
+			p.move(token.SEMICOLON)
+			p.space()
+			p.traverse(n.Post)
+		}
+		p.traverse(n.Body)
+		return false
 
 	case *ast.FuncDecl:
 		p.handleComment(n.Doc)
+		p.handleLineDirective(n)
+		// The func keyword of the type comes before the receiver and name
+		if n.Type != nil {
+			n.Type.Func = pc()
+			p.keywordless = n.Type
+		}
+		p.move(token.FUNC)
+		p.space()
 		if n.Recv != nil {
+			p.pushContext(ContextParams)
 			p.traverse(n.Recv)
+			p.popContext()
+			p.space()
 		}
 		p.traverse(n.Name)
 		p.traverse(n.Type)
-		p.traverse(n.Body)
-		p.newline()
+		if n.Body != nil {
+			p.declBody(n)
+			p.traverse(n.Body)
+		} else {
+			// External (e.g. assembly) function: end the signature line
+			// like the closing brace of a body would
+			p.newline()
+		}
+		if p.opts.BlankLines == BlankLinesDefault {
+			p.newline()
+		}
 		return false
 
 	case *ast.FuncType:
-		n.Func = pc()
-		p.move(token.FUNC)
+		wrap := p.hasHint(n, HintExpand) || p.exceedsLineWidth(n)
+		if n != p.keywordless {
+			n.Func = pc()
+			p.move(token.FUNC)
+		}
+		p.keywordless = nil
+		p.pushContext(ContextParams)
+		p.typeParams(n.TypeParams)
+		if wrap && n.Params != nil && len(n.Params.List) > 0 {
+			p.wrappedParams(n.Params)
+		} else {
+			markParens(n.Params, true)
+			p.traverse(n.Params)
+		}
+		if n.Results != nil {
+			p.space()
+			markParens(n.Results, len(n.Results.List) > 1 || len(n.Results.List) == 1 && len(n.Results.List[0].Names) > 0)
+			p.traverse(n.Results)
+		}
+		p.popContext()
+		return false
+
+	case *ast.FuncLit:
+		p.traverse(n.Type)
+		// The surrounding expression continues after the closing brace
+		p.continuedBlock = n.Body
+		p.litBody(n)
+		p.traverse(n.Body)
+		return false
 
 	case *ast.GenDecl:
 		p.handleComment(n.Doc)
+		if n.Lparen == token.NoPos && len(n.Specs) == 1 {
+			// Directives like //go:embed in the doc of a
+			// single spec only work above the keyword.
+			// Other docs only stay at their spec in a group.
+			if doc := specDoc(n.Specs[0]); hasDirective(doc) {
+				p.handleComment(doc)
+				p.hoistedDoc = doc
+			} else if doc != nil {
+				// Any valid position marks the declaration as parenthesized
+				n.Lparen, n.Rparen = 1, 1
+			}
+		}
+		p.handleLineDirective(n)
 		n.TokPos = pc()
 		p.move(n.Tok)
+		p.space()
+		if len(n.Specs) != 1 {
+			// go/printer always prints the parentheses
+			// of empty and grouped declarations
+			n.Lparen, n.Rparen = n.TokPos, n.TokPos
+		}
 		if n.Lparen != token.NoPos {
 			n.Lparen = pc()
 			p.move(token.LPAREN)
 			p.newline()
+			p.indent++
+			if p.opts.Columns && (n.Tok == token.CONST || n.Tok == token.VAR) {
+				p.alignLineComments(n)
+			}
 		}
-		traverseList(p, n.Specs)
+		if n.Tok == token.IMPORT && (p.opts.Gofumpt || p.opts.GroupImports) {
+			groups := importGroups(n.Specs, p.opts.LocalImportPrefixes)
+			n.Specs = slices.Concat(groups...)
+			for i, group := range groups {
+				if i > 0 {
+					p.blankLine()
+				}
+				traverseListSep(p, group, p.endLine)
+			}
+		} else if n.Tok == token.CONST && n.Lparen != token.NoPos {
+			traverseListSep(p, n.Specs, p.constSep(n.Specs))
+		} else if n.Lparen != token.NoPos {
+			// One spec per line as printed by go/printer
+			traverseListSep(p, n.Specs, p.endLine)
+		} else {
+			traverseList(p, n.Specs)
+		}
+		p.handleFloatingEnd(n)
 		if n.Rparen != token.NoPos {
+			p.indent--
 			n.Rparen = pc()
 			p.move(token.RPAREN)
 			p.newline()
@@ -352,17 +1023,37 @@ func (p *astPositioner) down(n ast.Node) bool {
 	case *ast.GoStmt:
 		n.Go = pc()
 		p.move(token.GO)
+		p.space()
 
 	case *ast.Ident:
 		n.NamePos = pc()
-		p.moveStr(n.Name)
+		p.moveToken(len(n.Name))
 
 	case *ast.IfStmt:
 		n.If = pc()
 		p.move(token.IF)
+		p.space()
+		p.initStmt(n.Init)
+		p.traverse(n.Cond)
+		if n.Else == nil {
+			p.traverse(n.Body)
+			return false
+		}
+		// else on the line of the closing brace
+		p.continuedBlock = n.Body
+		p.traverse(n.Body)
+		p.space()
+		p.move(token.ELSE)
+		p.space()
+		p.traverse(n.Else)
+		return false
 
 	case *ast.ImportSpec:
 		p.handleComment(n.Doc)
+		p.traverse(n.Name)
+		p.traverse(n.Path)
+		p.handleLineComment(n.Comment)
+		return false
 
 	case *ast.IncDecStmt:
 		p.traverse(n.X)
@@ -383,7 +1074,7 @@ func (p *astPositioner) down(n ast.Node) bool {
 		p.traverse(n.X)
 		n.Lbrack = pc()
 		p.move(token.LBRACK)
-		traverseList(p, n.Indices)
+		traverseListSep(p, n.Indices, p.comma)
 		n.Rbrack = pc()
 		p.move(token.RBRACK)
 		return false
@@ -391,28 +1082,49 @@ func (p *astPositioner) down(n ast.Node) bool {
 	case *ast.InterfaceType:
 		n.Interface = pc()
 		p.move(token.INTERFACE)
+		p.pushContext(ContextInterface)
+		p.interfaceElems(n.Methods, n == p.declaredInterface)
+		p.popContext()
+		return false
 
 	case *ast.KeyValueExpr:
+		oneLine := p.oneLine
+		p.oneLine = true
 		p.traverse(n.Key)
+		p.oneLine = oneLine
 		n.Colon = pc()
 		p.move(token.COLON)
+		p.space()
 		p.traverse(n.Value)
 
-		if p.listSize() > 1 && !isCompositeLit(n.Value) {
-			p.newline()
+		if p.listSize() > 1 && !isCompositeLit(n.Value) && !p.oneLine {
+			// The line ends after the comma (see eltComma)
+			p.eltLine = true
 		}
 		return false
 
 	case *ast.LabeledStmt:
+		// Labels are on their own line and outdented by one level
+		p.endLine()
+		p.indent--
 		p.traverse(n.Label)
+		p.indent++
 		n.Colon = pc()
 		p.move(token.COLON)
+		if _, ok := n.Stmt.(*ast.EmptyStmt); !ok {
+			p.newline()
+		}
 		p.traverse(n.Stmt)
 		return false
 
 	case *ast.MapType:
 		n.Map = pc()
 		p.move(token.MAP)
+		p.move(token.LBRACK)
+		p.traverse(n.Key)
+		p.move(token.RBRACK)
+		p.traverse(n.Value)
+		return false
 
 	case *ast.ParenExpr:
 		n.Lparen = pc()
@@ -425,30 +1137,61 @@ func (p *astPositioner) down(n ast.Node) bool {
 	case *ast.RangeStmt:
 		n.For = pc()
 		p.move(token.FOR)
+		p.space()
 		p.traverse(n.Key)
-		p.traverse(n.Value)
+		if n.Value != nil {
+			p.move(token.COMMA)
+			p.space()
+			p.traverse(n.Value)
+		}
 		if n.Tok != token.ILLEGAL {
+			p.space()
 			n.TokPos = pc()
 			p.move(n.Tok)
+			p.space()
 		}
 		n.Range = pc()
 		p.move(token.RANGE)
+		p.space()
 		p.traverse(n.X)
 		p.traverse(n.Body)
 		return false
 
 	case *ast.ReturnStmt:
+		wrap := len(n.Results) > 1 && (p.hasHint(n, HintExpand) || p.exceedsLineWidth(n))
 		n.Return = pc()
 		p.move(token.RETURN)
+		if len(n.Results) > 0 {
+			p.space()
+		}
+		if wrap {
+			// The first result stays on the line of the keyword,
+			// go/printer indents the following ones
+			p.indent++
+			traverseListSep(p, n.Results, p.commaLine)
+			p.indent--
+		} else {
+			traverseListSep(p, n.Results, p.comma)
+		}
+		return false
 
 	case *ast.SelectStmt:
 		n.Select = pc()
 		p.move(token.SELECT)
+		p.space()
+
+	case *ast.SelectorExpr:
+		p.traverse(n.X)
+		p.move(token.PERIOD)
+		p.traverse(n.Sel)
+		return false
 
 	case *ast.SendStmt:
 		p.traverse(n.Chan)
+		p.space()
 		n.Arrow = pc()
 		p.move(token.ARROW)
+		p.space()
 		p.traverse(n.Value)
 		return false
 
@@ -457,32 +1200,52 @@ func (p *astPositioner) down(n ast.Node) bool {
 		n.Lbrack = pc()
 		p.move(token.LBRACK)
 		p.traverse(n.Low)
+		p.move(token.COLON)
 		p.traverse(n.High)
-		p.traverse(n.Max)
+		if n.Slice3 {
+			p.move(token.COLON)
+			p.traverse(n.Max)
+		}
 		n.Rbrack = pc()
 		p.move(token.RBRACK)
 		return false
 
 	case *ast.StarExpr:
 		n.Star = pc()
-		p.moveStr("*")
+		p.move(token.MUL)
 
 	case *ast.StructType:
 		n.Struct = pc()
 		p.move(token.STRUCT)
-		p.inStruct = true
-		p.traverse(n.Fields)
-		p.inStruct = false
+		inline := p.isInlineStruct(n)
+		p.pushContext(ContextStruct)
+		if inline && n.Fields != nil {
+			p.inlineFields(n.Fields)
+		} else {
+			p.space()
+			p.traverse(n.Fields)
+		}
+		p.popContext()
 		return false
 
 	case *ast.SwitchStmt:
 		n.Switch = pc()
 		p.move(token.SWITCH)
+		p.space()
+		p.initStmt(n.Init)
+		p.traverse(n.Tag)
+		p.traverse(n.Body)
+		return false
 
 	case *ast.TypeAssertExpr:
 		p.traverse(n.X)
+		p.move(token.PERIOD)
 		n.Lparen = pc()
 		p.move(token.LPAREN)
+		if n.Type == nil {
+			// x.(type) of a type switch
+			p.move(token.TYPE)
+		}
 		p.traverse(n.Type)
 		n.Rparen = pc()
 		p.move(token.RPAREN)
@@ -490,56 +1253,594 @@ func (p *astPositioner) down(n ast.Node) bool {
 
 	case *ast.TypeSpec:
 		p.handleComment(n.Doc)
-		if n.Assign == token.NoPos {
-			return true
-		}
 		p.traverse(n.Name)
-		p.traverse(n.TypeParams)
-		n.Assign = pc()
-		p.move(token.ASSIGN)
+		p.typeParams(n.TypeParams)
+		if n.Assign != token.NoPos {
+			p.space()
+			n.Assign = pc()
+			p.move(token.ASSIGN)
+		}
+		p.space()
+		p.declaredInterface, _ = n.Type.(*ast.InterfaceType)
+		p.declaredStruct, _ = n.Type.(*ast.StructType)
+		p.orderFieldsOf(n)
 		p.traverse(n.Type)
 		return false
 
 	case *ast.TypeSwitchStmt:
 		n.Switch = pc()
 		p.move(token.SWITCH)
+		p.space()
+		p.initStmt(n.Init)
+		p.traverse(n.Assign)
+		p.traverse(n.Body)
+		return false
 
 	case *ast.UnaryExpr:
 		n.OpPos = pc()
 		p.move(n.Op)
 
+	case *ast.ValueSpec:
+		p.handleComment(n.Doc)
+		traverseListSep(p, n.Names, p.comma)
+		if n.Type != nil {
+			p.space()
+			p.traverse(n.Type)
+		}
+		if len(n.Values) > 0 {
+			p.space()
+			p.move(token.ASSIGN)
+			p.space()
+			traverseListSep(p, n.Values, p.comma)
+		}
+		p.handleLineComment(n.Comment)
+		return false
+
 	}
 
 	return true
 }
 
+// Positions a bad node with the width of the placeholder
+// text that go/printer prints for it
+func (p *astPositioner) badNode(from, to *token.Pos, text string) {
+	width := len(text)
+	if p.opts.BadNodeWidth > 0 {
+		width = p.opts.BadNodeWidth
+	}
+	*from = p.pc()
+	*to = *from + token.Pos(width)
+	p.moveToken(width)
+}
+
+// Positions the init statement of an if or switch statement
+// and the semicolon behind it
+func (p *astPositioner) initStmt(init ast.Stmt) {
+	if init == nil {
+		return
+	}
+	p.traverse(init)
+	p.move(token.SEMICOLON)
+	p.space()
+}
+
+// Returns true if the node would exceed the maximum line width when
+// it is printed on one line starting at the current position.
+// The width is estimated from the position counter, which does not
+// include the indentation and spaces before the node.
+func (p *astPositioner) exceedsLineWidth(n ast.Node) bool {
+	if p.opts.MaxLineWidth <= 0 || p.oneLine || p.fitting[n] {
+		return false
+	}
+	width := p.p - int(p.LineStart(p.Line(p.pc())))
+	// Printing every enclosing node would be quadratic in the nesting depth,
+	// so most too long expressions are caught by their flat width alone
+	if flat := p.flatWidth(n); flat >= 0 && width+flat > p.opts.MaxLineWidth {
+		return true
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, token.NewFileSet(), n); err != nil {
+		return false
+	}
+	for _, line := range bytes.Split(buf.Bytes(), []byte("\n")) {
+		if width+len(line) > p.opts.MaxLineWidth {
+			return true
+		}
+		// Following lines (e.g. of function literals) start at the indentation
+		width = 0
+	}
+	// Nested nodes end before n and start at a higher column
+	// or on one of the following lines, so they fit as well
+	if p.fitting == nil {
+		p.fitting = make(map[ast.Node]bool)
+	}
+	ast.Inspect(n, func(c ast.Node) bool {
+		if c == nil || p.fitting[c] {
+			return false
+		}
+		p.fitting[c] = true
+		return true
+	})
+	return false
+}
+
+// flatWidth returns a lower bound of the width of n printed on one line,
+// or -1 if n may be printed on more than one line
+func (p *astPositioner) flatWidth(n ast.Node) int {
+	if w, ok := p.flatWidths[n]; ok {
+		return w
+	}
+	var w int
+	switch n := n.(type) {
+	case *ast.FuncLit, *ast.CompositeLit, *ast.StructType, *ast.InterfaceType, ast.Stmt:
+		w = -1
+	case *ast.Ident:
+		w = len(n.Name)
+	case *ast.BasicLit:
+		w = len(n.Value)
+		if strings.Contains(n.Value, "\n") {
+			w = -1
+		}
+	case *ast.BinaryExpr:
+		w = len(n.Op.String())
+	case *ast.UnaryExpr:
+		w = len(n.Op.String())
+	case *ast.StarExpr, *ast.SelectorExpr, *ast.KeyValueExpr:
+		w = 1
+	case *ast.CallExpr, *ast.IndexExpr, *ast.ParenExpr:
+		w = 2
+	}
+	if w >= 0 {
+		ast.Inspect(n, func(c ast.Node) bool {
+			if c == n {
+				return true
+			}
+			if c != nil && w >= 0 {
+				if cw := p.flatWidth(c); cw < 0 {
+					w = -1
+				} else {
+					w += cw
+				}
+			}
+			return false
+		})
+	}
+	if p.flatWidths == nil {
+		p.flatWidths = make(map[ast.Node]int)
+	}
+	p.flatWidths[n] = w
+	return w
+}
+
+// Positions the parenthesized arguments of the call,
+// one per line if wrap is set
+func (p *astPositioner) callArgs(n *ast.CallExpr, wrap bool) {
+	n.Lparen = p.pc()
+	p.move(token.LPAREN)
+	if wrap {
+		// One argument per line, each with a trailing comma
+		p.newline()
+		p.indent++
+		traverseListSep(p, n.Args, func() {
+			if n.Ellipsis != token.NoPos && p.index() == p.listSize()-1 {
+				n.Ellipsis = p.pc()
+				p.move(token.ELLIPSIS)
+			}
+			p.commaLine()
+		})
+		p.indent--
+	} else {
+		traverseListSep(p, n.Args, p.comma)
+		if n.Ellipsis != token.NoPos {
+			n.Ellipsis = p.pc()
+			p.move(token.ELLIPSIS)
+		}
+	}
+	n.Rparen = p.pc()
+	p.move(token.RPAREN)
+}
+
+// Positions the parameters of a signature one per line
+func (p *astPositioner) wrappedParams(params *ast.FieldList) {
+	params.Opening = p.pc()
+	p.move(token.LPAREN)
+	p.newline()
+	p.indent++
+	traverseListSep(p, params.List, p.commaLine)
+	p.handleFloatingEnd(params)
+	p.indent--
+	params.Closing = p.pc()
+	p.move(token.RPAREN)
+}
+
+// Positions the methods and embedded elements of an interface.
+// Interfaces of type declarations are spread over multiple lines,
+// which go/printer fills with one element per line. All other
+// interfaces (e.g. inline constraints) are kept on one line.
+// Union elements like ~int | ~string always stay on one line.
+func (p *astPositioner) interfaceElems(elems *ast.FieldList, multiline bool) {
+	if elems == nil {
+		return
+	}
+	// go/printer spreads all other interfaces over multiple lines too
+	multiline = (multiline && len(elems.List) > 0) || (len(elems.List) > 0 && !isOneLineFieldList(elems))
+	if multiline {
+		p.space()
+	}
+	elems.Opening = p.pc()
+	p.move(token.LBRACE)
+	if multiline {
+		p.newline()
+		p.indent++
+		traverseListSep(p, elems.List, p.endLine)
+	} else {
+		// interface{ A; B }
+		p.space()
+		traverseListSep(p, elems.List, p.semicolon)
+		if len(elems.List) > 0 {
+			p.space()
+		}
+	}
+	p.handleFloatingEnd(elems)
+	if multiline {
+		p.endLine()
+		p.indent--
+	}
+	elems.Closing = p.pc()
+	p.move(token.RBRACE)
+}
+
+// Positions the brackets of a type parameter list, which are always
+// printed, on the line of the type or function name. Brackets
+// without positions are positioned too.
+func (p *astPositioner) typeParams(params *ast.FieldList) {
+	if params == nil {
+		return
+	}
+	p.pushContext(ContextTypeParams)
+	params.Opening = p.pc()
+	p.move(token.LBRACK)
+	traverseListSep(p, params.List, p.comma)
+	params.Closing = p.pc()
+	p.move(token.RBRACK)
+	p.popContext()
+}
+
 func (p *astPositioner) handleComment(c *ast.CommentGroup) {
+	p.handleIndentedComment(c, "")
+}
+
+// Positions the comment group on its own lines with
+// the given indentation in front of each comment
+func (p *astPositioner) handleIndentedComment(c *ast.CommentGroup, indent string) {
 	if c == nil {
 		return
 	}
+	if c == p.hoistedDoc {
+		p.hoistedDoc = nil
+		return
+	}
 
 	p.comments = append(p.comments, c)
-	lineStart := p.File.LineStart(p.File.Line(p.pc()))
-	if lineStart != p.pc() {
-		p.newline()
-	}
+	p.endLine()
 	for _, c := range c.List {
+		p.moveStr(indent)
 		c.Slash = p.pc()
-		p.moveStr(c.Text)
+		p.moveText(c.Text)
 		p.newline()
 	}
 }
 
+// Positions a comment group at the end of the current line
+// and starts a new line after it
+func (p *astPositioner) handleLineComment(c *ast.CommentGroup) {
+	if c == nil {
+		return
+	}
+
+	p.comments = append(p.comments, c)
+	column, aligned := p.commentColumns[c]
+	for i, comment := range c.List {
+		lineStart := int(p.LineStart(p.Line(p.pc())))
+		if aligned && i == 0 && lineStart+p.indent+column > p.p {
+			p.p = lineStart + p.indent + column
+		} else {
+			p.moveStr(" ")
+		}
+		comment.Slash = p.pc()
+		p.moveText(comment.Text)
+	}
+	p.newline()
+}
+
+// Returns the comment group that follows the package name on the
+// same line (e.g. "package foo // comment"). The line is only known
+// from the original FileSet, so without it there is none.
+// Must be called before the positions are rewritten.
+func findPackageComment(f *ast.File, origFset *token.FileSet) *ast.CommentGroup {
+	if origFset == nil || f.Name == nil || !f.Name.End().IsValid() {
+		return nil
+	}
+	next := f.FileEnd
+	if len(f.Decls) > 0 {
+		next = nodeStart(f.Decls[0])
+	}
+	for _, c := range f.Comments {
+		if c == f.Doc || c.Pos() < f.Name.End() {
+			continue
+		}
+		if next.IsValid() && c.Pos() >= next {
+			return nil
+		}
+		if origFset.Position(c.Pos()).Line != origFset.Position(f.Name.End()).Line {
+			return nil
+		}
+		return c
+	}
+	return nil
+}
+
+// Returns the comment groups above the package doc or, if there
+// is none, above the package clause (e.g. //go:build lines or a
+// license header).
+// Must be called before the positions are rewritten.
+func findHeaderComments(f *ast.File) []*ast.CommentGroup {
+	start := f.Package
+	if f.Doc != nil {
+		start = f.Doc.Pos()
+	}
+	if !start.IsValid() {
+		return nil
+	}
+	var header []*ast.CommentGroup
+	for _, c := range f.Comments {
+		if c == f.Doc || !c.Pos().IsValid() || c.End() > start {
+			continue
+		}
+		header = append(header, c)
+	}
+	return header
+}
+
+// Returns true for nil interfaces and interfaces holding a nil pointer.
+// It runs for every node, so the node types of go/ast are checked by a
+// type switch and only other node types by reflection.
+func isNil(n ast.Node) bool {
+	switch n := n.(type) {
+	case nil:
+		return true
+	case *ast.Comment:
+		return n == nil
+	case *ast.CommentGroup:
+		return n == nil
+	case *ast.Field:
+		return n == nil
+	case *ast.FieldList:
+		return n == nil
+	case *ast.BadExpr:
+		return n == nil
+	case *ast.Ident:
+		return n == nil
+	case *ast.Ellipsis:
+		return n == nil
+	case *ast.BasicLit:
+		return n == nil
+	case *ast.FuncLit:
+		return n == nil
+	case *ast.CompositeLit:
+		return n == nil
+	case *ast.ParenExpr:
+		return n == nil
+	case *ast.SelectorExpr:
+		return n == nil
+	case *ast.IndexExpr:
+		return n == nil
+	case *ast.IndexListExpr:
+		return n == nil
+	case *ast.SliceExpr:
+		return n == nil
+	case *ast.TypeAssertExpr:
+		return n == nil
+	case *ast.CallExpr:
+		return n == nil
+	case *ast.StarExpr:
+		return n == nil
+	case *ast.UnaryExpr:
+		return n == nil
+	case *ast.BinaryExpr:
+		return n == nil
+	case *ast.KeyValueExpr:
+		return n == nil
+	case *ast.ArrayType:
+		return n == nil
+	case *ast.StructType:
+		return n == nil
+	case *ast.FuncType:
+		return n == nil
+	case *ast.InterfaceType:
+		return n == nil
+	case *ast.MapType:
+		return n == nil
+	case *ast.ChanType:
+		return n == nil
+	case *ast.BadStmt:
+		return n == nil
+	case *ast.DeclStmt:
+		return n == nil
+	case *ast.EmptyStmt:
+		return n == nil
+	case *ast.LabeledStmt:
+		return n == nil
+	case *ast.ExprStmt:
+		return n == nil
+	case *ast.SendStmt:
+		return n == nil
+	case *ast.IncDecStmt:
+		return n == nil
+	case *ast.AssignStmt:
+		return n == nil
+	case *ast.GoStmt:
+		return n == nil
+	case *ast.DeferStmt:
+		return n == nil
+	case *ast.ReturnStmt:
+		return n == nil
+	case *ast.BranchStmt:
+		return n == nil
+	case *ast.BlockStmt:
+		return n == nil
+	case *ast.IfStmt:
+		return n == nil
+	case *ast.CaseClause:
+		return n == nil
+	case *ast.SwitchStmt:
+		return n == nil
+	case *ast.TypeSwitchStmt:
+		return n == nil
+	case *ast.CommClause:
+		return n == nil
+	case *ast.SelectStmt:
+		return n == nil
+	case *ast.ForStmt:
+		return n == nil
+	case *ast.RangeStmt:
+		return n == nil
+	case *ast.ImportSpec:
+		return n == nil
+	case *ast.ValueSpec:
+		return n == nil
+	case *ast.TypeSpec:
+		return n == nil
+	case *ast.BadDecl:
+		return n == nil
+	case *ast.GenDecl:
+		return n == nil
+	case *ast.FuncDecl:
+		return n == nil
+	case *ast.File:
+		return n == nil
+	}
+	v := reflect.ValueOf(n)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// Sets the end of the literal in go/ast versions that store it in
+// the ValueEnd field. Otherwise End() would return the old position.
+func setValueEnd(lit *ast.BasicLit, end token.Pos) {
+	field := reflect.ValueOf(lit).Elem().FieldByName("ValueEnd")
+	if field.IsValid() && field.Type() == reflect.TypeOf(token.NoPos) {
+		field.SetInt(int64(end))
+	}
+}
+
+// Returns the separator of the specs of a const block. Each spec is
+// on its own line and every iota sequence after the first one starts
+// after an empty line.
+func (p *astPositioner) constSep(specs []ast.Spec) func() {
+	i := 0
+	return func() {
+		i++
+		if i < len(specs) && startsIotaSequence(specs[i]) {
+			p.blankLine()
+		} else {
+			p.endLine()
+		}
+	}
+}
+
+// Returns true if the values of the const spec use iota
+func startsIotaSequence(spec ast.Spec) bool {
+	valueSpec, ok := spec.(*ast.ValueSpec)
+	if !ok {
+		return false
+	}
+	found := false
+	for _, value := range valueSpec.Values {
+		ast.Inspect(value, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// Returns the doc comment of an import, type or value spec
+func specDoc(spec ast.Spec) *ast.CommentGroup {
+	switch s := spec.(type) {
+	case *ast.ImportSpec:
+		return s.Doc
+	case *ast.TypeSpec:
+		return s.Doc
+	case *ast.ValueSpec:
+		return s.Doc
+	}
+	return nil
+}
+
+// Returns true if the comment group contains a directive like
+// //go:embed, //line or //export (see go/ast.CommentGroup.Text)
+func hasDirective(c *ast.CommentGroup) bool {
+	if c == nil {
+		return false
+	}
+	for _, c := range c.List {
+		if isDirective(strings.TrimPrefix(c.Text, "//")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether c (without the leading //) is a directive
+// comment, following the rules of go/ast
+func isDirective(c string) bool {
+	if strings.HasPrefix(c, "line ") || strings.HasPrefix(c, "extern ") || strings.HasPrefix(c, "export ") {
+		return true
+	}
+	// "//[a-z0-9]+:[a-z0-9]"
+	colon := strings.Index(c, ":")
+	if colon <= 0 || colon+1 >= len(c) {
+		return false
+	}
+	for i := 0; i <= colon+1; i++ {
+		if i == colon {
+			continue
+		}
+		b := c[i]
+		if !('a' <= b && b <= 'z' || '0' <= b && b <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if both declarations are single line var, const,
+// type or import declarations of the same kind without doc comments
+func isDeclGroup(prev, next ast.Decl) bool {
+	a, ok := prev.(*ast.GenDecl)
+	if !ok {
+		return false
+	}
+	b, ok := next.(*ast.GenDecl)
+	if !ok {
+		return false
+	}
+	return a.Tok == b.Tok && b.Doc == nil && !a.Lparen.IsValid() && !b.Lparen.IsValid()
+}
+
 func hasNestedComposite(composite *ast.CompositeLit) bool {
 	for _, child := range composite.Elts {
-		switch n := child.(type) {
-		case *ast.CompositeLit:
+		if kv, ok := child.(*ast.KeyValueExpr); ok {
+			child = kv.Value
+		}
+		if isCompositeLit(child) {
 			return true
-		case *ast.KeyValueExpr:
-			_, ok := n.Value.(*ast.CompositeLit)
-			if ok {
-				return true
-			}
 		}
 	}
 	return false
@@ -565,3 +1866,11 @@ func isCompositeLit(expr ast.Expr) bool {
 	}
 	return false
 }
+
+func isClause(stmt ast.Stmt) bool {
+	switch stmt.(type) {
+	case *ast.CaseClause, *ast.CommClause:
+		return true
+	}
+	return false
+}
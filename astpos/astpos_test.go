@@ -5,16 +5,19 @@ import (
 	"go/ast"
 	"go/format"
 	"go/parser"
+	"go/printer"
 	"go/token"
 	"log"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	"golang.org/x/tools/imports"
 )
 
-func TestAstPos(t *testing.T) {
-	src := `package astpos
+// Sample source that covers most supported node types
+const sampleSource = `package astpos
 	
 	// comment 0
 	type MyStruct struct {
@@ -102,6 +105,9 @@ func TestAstPos(t *testing.T) {
 	}
 	`
 
+func TestAstPos(t *testing.T) {
+	src := sampleSource
+
 	expected := `package astpos
 
 import "fmt"
@@ -205,29 +211,1722 @@ var _ = map[string]map[string]int{
 	}
 }
 
-func writeAST(t *testing.T, f *ast.File, fset *token.FileSet) string {
-	formatted := &bytes.Buffer{}
-	if err := format.Node(formatted, fset, f); err != nil {
-		t.Fatal(err)
+func TestBasicLitRewriter(t *testing.T) {
+	src := `package astpos
+
+	var a = 0xff + 0xab
+	var b = 10
+	`
+
+	expected := `package astpos
+
+var a = 0xFF + 0xAB
+var b = 10
+`
+
+	upperHex := func(lit *ast.BasicLit) {
+		if lit.Kind == token.INT && strings.HasPrefix(lit.Value, "0x") {
+			lit.Value = "0x" + strings.ToUpper(lit.Value[2:])
+		}
 	}
-	importProcessed, err := imports.Process("", formatted.Bytes(), nil)
+	f := parseSource(t, src)
+	f, fset := RewritePositions(f, WithBasicLitRewriter(upperHex))
+
+	// The positions after each literal must account for the rewritten value
+	ast.Inspect(f, func(n ast.Node) bool {
+		if bin, ok := n.(*ast.BinaryExpr); ok {
+			lit := bin.X.(*ast.BasicLit)
+			end := lit.ValuePos + token.Pos(len(lit.Value))
+			if end != bin.OpPos {
+				t.Errorf("operator at %d does not follow the literal ending at %d", bin.OpPos, end)
+			}
+		}
+		return true
+	})
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestFuncDeclWithoutBody(t *testing.T) {
+	src := `package astpos
+
+	// fast is implemented in assembly
+	func fast(x int) int
+	func slow(x int) int {
+		return fast(x)
+	}
+	func external()
+	`
+
+	expected := `package astpos
+
+// fast is implemented in assembly
+func fast(x int) int
+
+func slow(x int) int {
+	return fast(x)
+}
+
+func external()
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	for _, decl := range f.Decls {
+		if fn := decl.(*ast.FuncDecl); fn.Body == nil && fn.End() != fn.Type.End() {
+			t.Errorf("%s ends at %d instead of its signature end %d", fn.Name.Name, fn.End(), fn.Type.End())
+		}
+	}
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestVariadicInterfaceMethod(t *testing.T) {
+	src := `package astpos
+
+	type Logger interface {
+		Printf(format string, args ...any)
+		Level() int
+	}
+	`
+
+	expected := `package astpos
+
+type Logger interface {
+	Printf(format string, args ...any)
+	Level() int
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	ast.Inspect(f, func(n ast.Node) bool {
+		if e, ok := n.(*ast.Ellipsis); ok && e.Elt.Pos() != e.Ellipsis+3 {
+			t.Errorf("element type at %d does not follow the ellipsis at %d", e.Elt.Pos(), e.Ellipsis)
+		}
+		return true
+	})
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestSelectSendClause(t *testing.T) {
+	src := `package astpos
+
+	func pump(in <-chan int, out chan<- int) {
+		select {
+		case out <- 1:
+			println("sent")
+		case v := <-in:
+			println(v)
+		}
+	}
+	`
+
+	expected := `package astpos
+
+func pump(in <-chan int, out chan<- int) {
+	select {
+	case out <- 1:
+		println("sent")
+	case v := <-in:
+		println(v)
+	}
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	ast.Inspect(f, func(n ast.Node) bool {
+		clause, ok := n.(*ast.CommClause)
+		if !ok {
+			return true
+		}
+		if send, ok := clause.Comm.(*ast.SendStmt); ok {
+			if send.Chan.End() != send.Arrow || send.Value.Pos() != send.Arrow+2 {
+				t.Errorf("send statement %d <- %d is not laid out around its arrow at %d", send.Chan.Pos(), send.Value.Pos(), send.Arrow)
+			}
+		}
+		colonLine := fset.Position(clause.Colon).Line
+		if bodyLine := fset.Position(clause.Body[0].Pos()).Line; bodyLine != colonLine+1 {
+			t.Errorf("clause body starts on line %d, expected %d", bodyLine, colonLine+1)
+		}
+		return true
+	})
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestNestedArrayComposites(t *testing.T) {
+	src := `package astpos
+
+	var a = [][]int{{1, 2}, {3, 4}}
+	var b = [2][2]int{{1, 2}, {3, 4}}
+	var c = [][]int{{1}, {2, 3, 4, 5}, {}}
+	`
+
+	expected := `package astpos
+
+var a = [][]int{
+	{1, 2},
+	{3, 4},
+}
+var b = [2][2]int{
+	{1, 2},
+	{3, 4},
+}
+var c = [][]int{
+	{1},
+	{
+		2, 3, 4, 5,
+	},
+	{},
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	ast.Inspect(f, func(n ast.Node) bool {
+		outer, ok := n.(*ast.CompositeLit)
+		if !ok || outer.Type == nil {
+			return true
+		}
+		for _, elt := range outer.Elts {
+			inner := elt.(*ast.CompositeLit)
+			if inner.Lbrace <= outer.Lbrace || inner.Rbrace >= outer.Rbrace {
+				t.Errorf("inner composite %d-%d is not within the outer braces %d-%d",
+					inner.Lbrace, inner.Rbrace, outer.Lbrace, outer.Rbrace)
+			}
+		}
+		return true
+	})
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestPackageClauseComment(t *testing.T) {
+	src := `package foo // build X
+
+	// comment 0
+	var a = 1
+	`
+
+	expected := `package foo // build X
+
+// comment 0
+var a = 1
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments|parser.SkipObjectResolution)
 	if err != nil {
 		t.Fatal(err)
 	}
+	f, fset = RewritePositions(f, WithOriginalFileSet(fset))
+	if len(f.Comments) != 2 {
+		t.Fatalf("expected 2 comment groups, got %d", len(f.Comments))
+	}
+	if fset.Position(f.Comments[0].Pos()).Line != fset.Position(f.Name.Pos()).Line {
+		t.Error("package comment is not on the line of the package clause")
+	}
+	checkResult(t, writeAST(t, f, fset), expected)
+}
 
-	return string(importProcessed)
+func TestPackageClauseFloatingComment(t *testing.T) {
+	src := `package foo
+
+// floating
+
+func x() {
 }
+`
 
-// For debugging
-func saveToFile(code, filename string) {
-	out, err := os.Create(filename)
-	if err != nil {
-		log.Fatal(err)
+	// Without the original FileSet the line of the comment is
+	// unknown, so it is not moved behind the package name
+	f, fset := RewritePositions(parseSource(t, src))
+	for _, c := range f.Comments {
+		if fset.Position(c.Pos()).Line == fset.Position(f.Name.Pos()).Line {
+			t.Errorf("comment %q is moved to the line of the package clause", c.Text())
+		}
 	}
-	defer out.Close()
 
-	_, err = out.Write([]byte(code))
+	fset = token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments|parser.SkipObjectResolution)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
+	}
+	f, fset = RewritePositions(f, WithOriginalFileSet(fset))
+	checkResult(t, writeAST(t, f, fset), src)
+}
+
+func TestNestedGenericInstantiation(t *testing.T) {
+	src := `package astpos
+
+	type Holder struct {
+		m Map[string, List[Pair[int, error]]]
+	}
+
+	func lookup() Map[string, List[Pair[int, error]]] {
+		return nil
+	}
+	`
+
+	expected := `package astpos
+
+type Holder struct {
+	m Map[string, List[Pair[int, error]]]
+}
+
+func lookup() Map[string, List[Pair[int, error]]] {
+	return nil
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	ast.Inspect(f, func(n ast.Node) bool {
+		var lbrack, rbrack token.Pos
+		var indices []ast.Expr
+		switch n := n.(type) {
+		case *ast.IndexExpr:
+			lbrack, rbrack, indices = n.Lbrack, n.Rbrack, []ast.Expr{n.Index}
+		case *ast.IndexListExpr:
+			lbrack, rbrack, indices = n.Lbrack, n.Rbrack, n.Indices
+		default:
+			return true
+		}
+		prev := lbrack
+		for _, index := range indices {
+			if index.Pos() <= prev {
+				t.Errorf("type argument at %d does not follow position %d", index.Pos(), prev)
+			}
+			prev = index.Pos()
+		}
+		if rbrack <= prev {
+			t.Errorf("closing bracket at %d does not follow the type arguments", rbrack)
+		}
+		return true
+	})
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestReturnMultipleValuesWithComposite(t *testing.T) {
+	src := `package astpos
+
+	func get() (int, T, error) {
+		return 1, T{a: 1, b: 2}, nil
+	}
+
+	func single() (T, error) {
+		return T{a: 1}, nil
+	}
+	`
+
+	expected := `package astpos
+
+func get() (int, T, error) {
+	return 1, T{
+		a: 1,
+		b: 2,
+	}, nil
+}
+
+func single() (T, error) {
+	return T{a: 1}, nil
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestValueSpecMultipleNames(t *testing.T) {
+	src := `package astpos
+
+	var x, y int = 1, 2
+
+	const a, b = iota, iota + 1
+
+	var (
+		// spec comment
+		c, d = "c", "d"
+	)
+	`
+
+	expected := `package astpos
+
+var x, y int = 1, 2
+
+const a, b = iota, iota + 1
+
+var (
+	// spec comment
+	c, d = "c", "d"
+)
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		end := spec.Names[len(spec.Names)-1].End()
+		if spec.Type != nil {
+			end = spec.Type.End()
+		}
+		if spec.Values[0].Pos() != end+token.Pos(len(token.ASSIGN.String())) {
+			t.Errorf("values at %d do not follow the \"=\" after %d", spec.Values[0].Pos(), end)
+		}
+		return false
+	})
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestForwardGoto(t *testing.T) {
+	src := `package astpos
+
+	func retry(i int) {
+		if i > 3 {
+			goto Later
+		}
+		i++
+	Later:
+		println(i)
+	}
+	`
+
+	expected := `package astpos
+
+func retry(i int) {
+	if i > 3 {
+		goto Later
+	}
+	i++
+Later:
+	println(i)
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	var jump *ast.BranchStmt
+	var label *ast.LabeledStmt
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BranchStmt:
+			jump = n
+		case *ast.LabeledStmt:
+			label = n
+		}
+		return true
+	})
+	if jump.Label.Pos() <= jump.TokPos {
+		t.Errorf("goto target at %d does not follow the goto keyword at %d", jump.Label.Pos(), jump.TokPos)
+	}
+	if label.Pos() <= jump.End() {
+		t.Errorf("label definition at %d does not follow the goto statement ending at %d", label.Pos(), jump.End())
+	}
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestMapCompositeKeys(t *testing.T) {
+	src := `package astpos
+
+	var short = map[Point]string{{1, 2}: "a"}
+	var long = map[Point]string{{1, 2}: "a", {3, 4}: "b", {5, 6}: "c"}
+	var keyed = map[Point][]int{{X: 1, Y: 2}: {1}, {X: 3, Y: 4}: {2}}
+	`
+
+	expected := `package astpos
+
+var short = map[Point]string{{1, 2}: "a"}
+var long = map[Point]string{
+	{1, 2}: "a",
+	{3, 4}: "b",
+	{5, 6}: "c",
+}
+var keyed = map[Point][]int{
+	{X: 1, Y: 2}: {1},
+	{X: 3, Y: 4}: {2},
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestStructTags(t *testing.T) {
+	src := `package astpos
+
+	type User struct {
+		// field comment
+		Name string ` + "`json:\"name,omitempty\" xml:\"name\"`" + `
+		Age int ` + "`json:\"age\" xml:\"age,attr\"`" + `
+		Skipped bool ` + "``" + `
+		Plain string
+	}
+	`
+
+	expected := `package astpos
+
+type User struct {
+	// field comment
+	Name    string ` + "`json:\"name,omitempty\" xml:\"name\"`" + `
+	Age     int    ` + "`json:\"age\" xml:\"age,attr\"`" + `
+	Skipped bool   ` + "``" + `
+	Plain   string
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	ast.Inspect(f, func(n ast.Node) bool {
+		field, ok := n.(*ast.Field)
+		if !ok || field.Tag == nil {
+			return true
+		}
+		if fset.Position(field.Tag.Pos()).Line != fset.Position(field.Pos()).Line {
+			t.Errorf("tag of field %s is not on the line of the field", field.Names[0].Name)
+		}
+		if field.Tag.Pos() != field.Type.End() {
+			t.Errorf("tag of field %s at %d does not follow its type", field.Names[0].Name, field.Tag.Pos())
+		}
+		return false
+	})
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestAddressOfComposite(t *testing.T) {
+	src := `package astpos
+
+	func f() {
+		x := &T{a: 1}
+		use(&T{a: 1}, 2)
+		l := []*T{&T{a: 1}, &T{a: 2}}
+		_, _ = x, l
+	}
+	`
+
+	expected := `package astpos
+
+func f() {
+	x := &T{a: 1}
+	use(&T{a: 1}, 2)
+	l := []*T{
+		&T{a: 1},
+		&T{a: 2},
+	}
+	_, _ = x, l
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	ast.Inspect(f, func(n ast.Node) bool {
+		if u, ok := n.(*ast.UnaryExpr); ok && u.X.Pos() != u.OpPos+1 {
+			t.Errorf("composite at %d does not directly follow the & at %d", u.X.Pos(), u.OpPos)
+		}
+		return true
+	})
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestFuncTypeResults(t *testing.T) {
+	src := `package astpos
+
+	func (s *S) Handler() func(int) error {
+		return nil
+	}
+
+	func curry() func(int) func(string) (bool, error) {
+		return nil
+	}
+	`
+
+	expected := `package astpos
+
+func (s *S) Handler() func(int) error {
+	return nil
+}
+
+func curry() func(int) func(string) (bool, error) {
+	return nil
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	for _, decl := range f.Decls {
+		fn := decl.(*ast.FuncDecl)
+		if fset.Position(fn.Pos()).Line != fset.Position(fn.Type.End()).Line {
+			t.Errorf("signature of %s spans multiple lines", fn.Name.Name)
+		}
+	}
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestEndOfLineComments(t *testing.T) {
+	src := `package astpos
+
+	import (
+		"fmt" // printing
+		str "strings" // renamed
+	)
+
+	type MyStruct struct {
+		// field comment
+		Name string ` + "`json:\"name\"`" + ` // user-visible name
+		Age int // in years
+		Nick string
+	}
+
+	const (
+		A = 1 // first
+		B = 2
+		LongerName = 3 // third
+	)
+
+	func f() {
+		var y = 2 // local
+		fmt.Println(str.ToUpper("x"), y)
 	}
+	`
+
+	expected := `package astpos
+
+import (
+	"fmt"         // printing
+	str "strings" // renamed
+)
+
+type MyStruct struct {
+	// field comment
+	Name string ` + "`json:\"name\"`" + ` // user-visible name
+	Age  int    // in years
+	Nick string
+}
+
+const (
+	A          = 1 // first
+	B          = 2
+	LongerName = 3 // third
+)
+
+func f() {
+	var y = 2 // local
+	fmt.Println(str.ToUpper("x"), y)
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestDirectiveComments(t *testing.T) {
+	src := `package astpos
+
+	import _ "embed"
+
+	//go:generate stringer -type=Kind
+	type Kind int
+
+	// fast is not inlined
+	//
+	//go:noinline
+	func fast() int {
+		return 1
+	}
+
+	var hello string
+
+	var (
+		//go:embed b.txt
+		b []byte
+	)
+	`
+
+	expected := `package astpos
+
+import _ "embed"
+
+//go:generate stringer -type=Kind
+type Kind int
+
+// fast is not inlined
+//
+//go:noinline
+func fast() int {
+	return 1
+}
+
+//go:embed a.txt
+var hello string
+var (
+	//go:embed b.txt
+	b []byte
+)
+`
+
+	f := parseSource(t, src)
+	// A doc comment set on the spec instead of the declaration
+	spec := f.Decls[3].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	spec.Doc = &ast.CommentGroup{List: []*ast.Comment{{Text: "//go:embed a.txt"}}}
+
+	f, fset := RewritePositions(f)
+	got := writeAST(t, f, fset)
+	checkResult(t, got, expected)
+
+	// The directive has to be attached to the var to take effect
+	parsed := parseSource(t, got)
+	doc := parsed.Decls[3].(*ast.GenDecl).Doc
+	if doc == nil || doc.List[0].Text != "//go:embed a.txt" {
+		t.Errorf("//go:embed is not the doc comment of its declaration")
+	}
+}
+
+func TestBuildConstraints(t *testing.T) {
+	src := `//go:build linux && amd64
+	// +build linux,amd64
+
+	// Package astpos is only built on linux
+	package astpos
+
+	var x = 1
+	`
+
+	expected := `//go:build linux && amd64
+// +build linux,amd64
+
+// Package astpos is only built on linux
+package astpos
+
+var x = 1
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	got := writeAST(t, f, fset)
+	checkResult(t, got, expected)
+
+	parsed := parseSource(t, got)
+	if parsed.Doc == nil || parsed.Doc.Text() != "Package astpos is only built on linux\n" {
+		t.Errorf("build constraints were merged into the package doc")
+	}
+}
+
+func TestMultilineBlockComments(t *testing.T) {
+	src := `package astpos
+
+/*
+Doc comment
+on multiple lines
+*/
+var x = 1
+
+type T struct {
+	/* field
+	doc */
+	A int /* multiline
+	end of line comment */
+	B int
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), src)
+
+	// The line breaks within the comments are registered in the file
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			lines := fset.Position(c.End()).Line - fset.Position(c.Pos()).Line
+			if want := strings.Count(c.Text, "\n"); lines != want {
+				t.Errorf("comment %q spans %d line breaks, expected %d", c.Text, lines, want)
+			}
+		}
+	}
+}
+
+func TestMultilineRawStrings(t *testing.T) {
+	src := "package astpos\n\n" +
+		"const usage = `usage: cmd [flags]\n\n" +
+		"  -v\tverbose\n`\n\n" +
+		"func f() {\n" +
+		"\tg(`a\nb`, x) // g takes a raw string\n" +
+		"}\n\n" +
+		"// The last declaration\n" +
+		"var last = 1\n"
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, fset = RewritePositions(f, WithColumns(), WithOriginalFileSet(fset))
+	checkResult(t, writeAST(t, f, fset), src)
+
+	// The line breaks within the literals are registered in the file
+	ast.Inspect(f, func(n ast.Node) bool {
+		lit, ok := n.(*ast.BasicLit)
+		if !ok {
+			return true
+		}
+		lines := fset.Position(lit.End()).Line - fset.Position(lit.Pos()).Line
+		if want := strings.Count(lit.Value, "\n"); lines != want {
+			t.Errorf("literal %q spans %d line breaks, expected %d", lit.Value, lines, want)
+		}
+		return true
+	})
+	if line := fset.Position(f.Decls[2].Pos()).Line; line != 12 {
+		t.Errorf("last declaration is on line %d, expected 12", line)
+	}
+	if line := fset.Position(f.Comments[1].Pos()).Line; line != 11 {
+		t.Errorf("doc comment of the last declaration is on line %d, expected 11", line)
+	}
+}
+
+func TestSynthesizedFieldComments(t *testing.T) {
+	src := `package astpos
+
+	type User struct {
+		Name string
+		Age int
+	}
+	`
+
+	expected := `package astpos
+
+type User struct {
+	// Name of the user
+	Name string // user-visible name
+	Age  int    // in years
+}
+`
+
+	f := parseSource(t, src)
+	fields := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type.(*ast.StructType).Fields.List
+	fields[0].Doc = &ast.CommentGroup{List: []*ast.Comment{{Text: "// Name of the user"}}}
+	fields[0].Comment = &ast.CommentGroup{List: []*ast.Comment{{Text: "// user-visible name"}}}
+	fields[1].Comment = &ast.CommentGroup{List: []*ast.Comment{{Text: "// in years"}}}
+
+	f, fset := RewritePositions(f)
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	for _, field := range fields {
+		if fset.Position(field.Comment.Pos()).Line != fset.Position(field.Type.End()).Line {
+			t.Errorf("line comment %q is not on the line of its field", field.Comment.Text())
+		}
+	}
+}
+
+func TestTypeParams(t *testing.T) {
+	src := `package astpos
+
+	type Pair[K comparable, V any] struct {
+		Key K
+		Val V
+	}
+
+	func Map[T, U any, N ~int | ~int64](xs []T, f func(T) U) []U {
+		return nil
+	}
+
+	type List []T
+	`
+
+	expected := `package astpos
+
+type Pair[K comparable, V any] struct {
+	Key K
+	Val V
+}
+
+func Map[T, U any, N ~int | ~int64](xs []T, f func(T) U) []U {
+	return nil
+}
+
+type List[T any] []T
+`
+
+	f := parseSource(t, src)
+	// Type parameters without positions
+	list := f.Decls[2].(*ast.GenDecl).Specs[0].(*ast.TypeSpec)
+	list.TypeParams = &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{ast.NewIdent("T")}, Type: ast.NewIdent("any")}}}
+
+	f, fset := RewritePositions(f)
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		var name *ast.Ident
+		var params *ast.FieldList
+		switch n := n.(type) {
+		case *ast.TypeSpec:
+			name, params = n.Name, n.TypeParams
+		case *ast.FuncDecl:
+			name, params = n.Name, n.Type.TypeParams
+		default:
+			return true
+		}
+		if params.Opening >= params.Closing || params.Pos() != params.Opening {
+			t.Errorf("brackets of the type parameters of %s are not positioned around them", name.Name)
+		}
+		if fset.Position(params.Closing).Line != fset.Position(name.Pos()).Line {
+			t.Errorf("type parameters of %s are not on the line of the name", name.Name)
+		}
+		return true
+	})
+}
+
+func TestInterfaces(t *testing.T) {
+	src := `package astpos
+
+	type Shape interface { Area() float64; Perimeter() float64; fmt.Stringer }
+
+	type Number interface { ~int | ~int64 | float64 }
+
+	type Empty interface{}
+
+	func Sum[T interface{ ~int | ~float64 }](xs ...T) (s T) {
+		return
+	}
+	`
+
+	expected := `package astpos
+
+import "fmt"
+
+type Shape interface {
+	Area() float64
+	Perimeter() float64
+	fmt.Stringer
+}
+type Number interface {
+	~int | ~int64 | float64
+}
+type Empty interface{}
+
+func Sum[T interface{ ~int | ~float64 }](xs ...T) (s T) {
+	return
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestLabeledStmt(t *testing.T) {
+	src := `package astpos
+
+	func f() { outer: for { inner: switch { case true: break outer }; goto end }; end: }
+	`
+
+	expected := `package astpos
+
+func f() {
+outer:
+	for {
+	inner:
+		switch {
+		case true:
+			break outer
+		}
+		goto end
+	}
+end:
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	f, fset = RewritePositions(parseSource(t, src), WithColumns())
+	outer := f.Decls[0].(*ast.FuncDecl).Body.List[0].(*ast.LabeledStmt)
+	inner := outer.Stmt.(*ast.ForStmt).Body.List[0].(*ast.LabeledStmt)
+	for _, label := range []*ast.LabeledStmt{outer, inner} {
+		labelPos, stmtPos := fset.Position(label.Pos()), fset.Position(label.Stmt.Pos())
+		if labelPos.Line+1 != stmtPos.Line || labelPos.Column+1 != stmtPos.Column {
+			t.Errorf("Label %s at %s is not outdented above its statement at %s", label.Label.Name, labelPos, stmtPos)
+		}
+	}
+}
+
+func TestSelectStmt(t *testing.T) {
+	src := `package astpos
+
+	func f(a, b chan int) { select { case <-a: case v := <-b: _ = v; case b <- 1: default: } }
+	`
+
+	expected := `package astpos
+
+func f(a, b chan int) {
+	select {
+	case <-a:
+	case v := <-b:
+		_ = v
+	case b <- 1:
+	default:
+	}
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+}
+
+func TestSwitchStmt(t *testing.T) {
+	src := `package astpos
+
+	func f(v any) {
+		switch x := v.(int); x { case 1: }
+		switch y := v; z := y.(type) { case int: _ = z }
+		switch v.(type) {}
+		switch {}
+	}
+	`
+
+	expected := `package astpos
+
+func f(v any) {
+	switch x := v.(int); x {
+	case 1:
+	}
+	switch y := v; z := y.(type) {
+	case int:
+		_ = z
+	}
+	switch v.(type) {
+	}
+	switch {
+	}
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	// The columns match the formatted source
+	fset = token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", expected, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := nodeColumns(f, fset)
+	f, fset = RewritePositions(f, WithColumns())
+	if got := nodeColumns(f, fset); got != want {
+		t.Fatalf("Columns differ from the formatted source:\n%s\nexpected:\n%s", got, want)
+	}
+}
+
+func TestIfStmt(t *testing.T) {
+	src := `package astpos
+
+	func f(v any) {
+		if x := v.(int); x > 0 { _ = x } else if v == nil { return } else { v = nil }
+		if v != nil {}
+		if v == nil { if v != 0 { v = 1 } else { v = 2 } } else { v = 3 }
+	}
+	`
+
+	expected := `package astpos
+
+func f(v any) {
+	if x := v.(int); x > 0 {
+		_ = x
+	} else if v == nil {
+		return
+	} else {
+		v = nil
+	}
+	if v != nil {
+	}
+	if v == nil {
+		if v != 0 {
+			v = 1
+		} else {
+			v = 2
+		}
+	} else {
+		v = 3
+	}
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	// Each else follows the closing brace of its block, also if
+	// the block contains another if-else
+	ast.Inspect(f, func(n ast.Node) bool {
+		if s, ok := n.(*ast.IfStmt); ok && s.Else != nil {
+			if fset.Position(s.Else.Pos()).Line != fset.Position(s.Body.Rbrace).Line {
+				t.Errorf("else at %s is not on the line of the closing brace", fset.Position(s.Else.Pos()))
+			}
+		}
+		return true
+	})
+
+	// The columns match the formatted source
+	fset = token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", expected, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := nodeColumns(f, fset)
+	f, fset = RewritePositions(f, WithColumns())
+	if got := nodeColumns(f, fset); got != want {
+		t.Fatalf("Columns differ from the formatted source:\n%s\nexpected:\n%s", got, want)
+	}
+}
+
+func TestForStmt(t *testing.T) {
+	src := `package astpos
+
+	func f(n int) {
+		for i := 0; i < n; i++ { n-- }
+		for a, b := 0, n; a < b; a++ {}
+		for ; n > 0; {}
+		for n > 0 {}
+		for {}
+	}
+	`
+
+	expected := `package astpos
+
+func f(n int) {
+	for i := 0; i < n; i++ {
+		n--
+	}
+	for a, b := 0, n; a < b; a++ {
+	}
+	for n > 0 {
+	}
+	for n > 0 {
+	}
+	for {
+	}
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	// The columns match the formatted source
+	fset = token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", expected, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := nodeColumns(f, fset)
+	f, fset = RewritePositions(f, WithColumns())
+	if got := nodeColumns(f, fset); got != want {
+		t.Fatalf("Columns differ from the formatted source:\n%s\nexpected:\n%s", got, want)
+	}
+}
+
+func TestFuncLit(t *testing.T) {
+	src := `package astpos
+
+	func f() {
+		g(func(a int) { h(a) }, 1)
+		defer func() { recover() }()
+		var fn = func() int { if true { return 1 } else { return 2 } }
+		_ = fn
+	}
+	`
+
+	expected := `package astpos
+
+func f() {
+	g(func(a int) {
+		h(a)
+	}, 1)
+	defer func() {
+		recover()
+	}()
+	var fn = func() int {
+		if true {
+			return 1
+		} else {
+			return 2
+		}
+	}
+	_ = fn
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	call := body.List[0].(*ast.ExprStmt).X.(*ast.CallExpr)
+	lit := call.Args[0].(*ast.FuncLit)
+	if fset.Position(lit.End()).Line != fset.Position(call.Args[1].Pos()).Line {
+		t.Errorf("The call does not continue on the line of the closing brace")
+	}
+}
+
+func TestSliceExpr(t *testing.T) {
+	src := `package astpos
+
+	var s = []int{1, 2, 3}
+	var a, b, c, d = s[:], s[1:], s[:2], s[0:1:2]
+	`
+
+	expected := `package astpos
+
+var s = []int{1, 2, 3}
+var a, b, c, d = s[:], s[1:], s[:2], s[0:1:2]
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	// The colons lie between the indices
+	spec := f.Decls[1].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	slice3 := spec.Values[3].(*ast.SliceExpr)
+	if slice3.High.Pos() != slice3.Low.End()+1 || slice3.Max.Pos() != slice3.High.End()+1 {
+		t.Errorf("The indices of %s are not separated by colons", nodeString(slice3))
+	}
+	if slice3.Rbrack != slice3.Max.End() {
+		t.Errorf("The closing bracket does not follow the max index")
+	}
+}
+
+func TestMethodSignatures(t *testing.T) {
+	src := `package astpos
+
+	type I interface { M(a int) string; N(f func() int) }
+	`
+
+	expected := `package astpos
+
+type I interface {
+	M(a int) string
+	N(f func() int)
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+	if err := Verify(f, fset); err != nil {
+		t.Error(err)
+	}
+
+	methods := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type.(*ast.InterfaceType).Methods.List
+	for _, method := range methods {
+		if funcType := method.Type.(*ast.FuncType); funcType.Func.IsValid() {
+			t.Errorf("Interface method %s has a func keyword", method.Names[0].Name)
+		}
+	}
+
+	// The columns match the formatted source
+	fset = token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", expected, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := nodeColumns(f, fset)
+	f, fset = RewritePositions(f, WithColumns())
+	if got := nodeColumns(f, fset); got != want {
+		t.Fatalf("Columns differ from the formatted source:\n%s\nexpected:\n%s", got, want)
+	}
+}
+
+func TestConstBlocks(t *testing.T) {
+	src := `package astpos
+
+	const ( A = iota; B; C; D = "d"; E = iota * 10; F; G = 1 << iota )
+	`
+
+	expected := `package astpos
+
+const (
+	A = iota
+	B
+	C
+	D = "d"
+
+	E = iota * 10
+	F
+
+	G = 1 << iota
+)
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	specs := f.Decls[0].(*ast.GenDecl).Specs
+	for i := 1; i < len(specs); i++ {
+		if fset.Position(specs[i].Pos()).Line <= fset.Position(specs[i-1].Pos()).Line {
+			t.Errorf("Spec %d is not on its own line", i)
+		}
+	}
+}
+
+func TestBadNodes(t *testing.T) {
+	f := parseSource(t, `package astpos
+
+	var x, y = 1, 2
+
+	func f() {
+		x = 1
+	}
+	`)
+	spec := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	badExpr := &ast.BadExpr{}
+	spec.Values[0] = badExpr
+	badStmt := &ast.BadStmt{}
+	f.Decls[1].(*ast.FuncDecl).Body.List[0] = badStmt
+	badDecl := &ast.BadDecl{}
+	f.Decls = append(f.Decls, badDecl)
+
+	f, fset := RewritePositions(f)
+	expected := `package astpos
+
+var x, y = BadExpr, 2
+
+func f() {
+	BadStmt
+}
+
+BadDecl
+`
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, buf.String(), expected)
+	if badExpr.End() > spec.Values[1].Pos() {
+		t.Errorf("BadExpr ends at %d after the next value at %d", badExpr.End(), spec.Values[1].Pos())
+	}
+
+	RewritePositions(f, WithBadNodeWidth(3))
+	for _, n := range []ast.Node{badExpr, badStmt, badDecl} {
+		if n.End()-n.Pos() != 3 {
+			t.Errorf("%T is %d wide", n, n.End()-n.Pos())
+		}
+	}
+}
+
+func TestGeneratedSignatureParens(t *testing.T) {
+	typ := &ast.FuncType{
+		Params: &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{ast.NewIdent("a")}, Type: ast.NewIdent("int")}}},
+		Results: &ast.FieldList{List: []*ast.Field{
+			{Type: ast.NewIdent("int")},
+			{Type: ast.NewIdent("error")},
+		}},
+	}
+	RewriteNode(typ)
+	for _, fields := range []*ast.FieldList{typ.Params, typ.Results} {
+		first, last := fields.List[0], fields.List[len(fields.List)-1]
+		if fields.Opening+1 != first.Pos() || fields.Closing != last.End() {
+			t.Errorf("Parentheses at %d and %d are not around the fields from %d to %d", fields.Opening, fields.Closing, first.Pos(), last.End())
+		}
+	}
+}
+
+func TestCallExpr(t *testing.T) {
+	src := `package astpos
+
+	func f() {
+		g(a, b, c...)
+		g(aaaaaaaaaaaaaaaaaaaa, bbbbbbbbbbbbbbbbbbbb, cccccccccccccccccccc, dddddddddddddddddddd...)
+	}
+	`
+
+	expected := `package astpos
+
+func f() {
+	g(a, b, c...)
+	g(
+		aaaaaaaaaaaaaaaaaaaa,
+		bbbbbbbbbbbbbbbbbbbb,
+		cccccccccccccccccccc,
+		dddddddddddddddddddd...,
+	)
+}
+`
+
+	f := parseSource(t, src)
+	f, fset := RewritePositions(f, WithMaxLineWidth(60))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	// The parentheses and commas have a width
+	call := f.Decls[0].(*ast.FuncDecl).Body.List[0].(*ast.ExprStmt).X.(*ast.CallExpr)
+	if call.Args[0].Pos() != call.Lparen+1 {
+		t.Errorf("First argument at %d, expected %d", call.Args[0].Pos(), call.Lparen+1)
+	}
+	for i := 1; i < len(call.Args); i++ {
+		if call.Args[i].Pos() <= call.Args[i-1].End() {
+			t.Errorf("Argument %d at %d does not follow the comma after %d", i, call.Args[i].Pos(), call.Args[i-1].End())
+		}
+	}
+	if call.End() != call.Ellipsis+4 {
+		t.Errorf("Call ends at %d, expected %d", call.End(), call.Ellipsis+4)
+	}
+}
+
+func TestListCommas(t *testing.T) {
+	src := `package astpos
+
+	var a, b = 1, 2
+
+	func f[T, U any](x, y int, z T) (int, error) {
+		c, d := a, b
+		for k, v := range m {
+			_, _ = k, v
+		}
+		switch c {
+		case 1, 2:
+		}
+		_ = g[int, string]
+		_ = []int{c, d}
+		_ = map[int]int{1: c, 2: d}
+		return x, nil
+	}
+	`
+
+	f, _ := RewritePositions(parseSource(t, src), WithColumns())
+
+	// Every identifier and literal starts behind the previous one
+	// and the separator between them
+	prevEnd := token.NoPos
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.Ident, *ast.BasicLit:
+			if n.Pos() <= prevEnd {
+				t.Errorf("%s at %d does not follow the previous node ending at %d", nodeString(n), n.Pos(), prevEnd)
+			}
+			prevEnd = n.End()
+		}
+		return true
+	})
+}
+
+func TestNestedFieldLists(t *testing.T) {
+	src := `package astpos
+
+type S struct {
+	i interface{ M(a, b int) }
+	s struct {
+		n interface{ ~int | ~string }
+	}
+	f func(a, b int) (c int)
+}
+
+type C interface {
+	~int | ~string
+	error
+	interface{ A() }
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := nodeColumns(f, fset)
+
+	f, fset = RewritePositions(f, WithColumns())
+	if result := nodeColumns(f, fset); result != expected {
+		t.Fatalf("Columns differ from the parsed source:\n%s\nexpected:\n%s", result, expected)
+	}
+	checkResult(t, writeAST(t, f, fset), src)
+}
+
+func TestGoDeferFuncLit(t *testing.T) {
+	src := `package astpos
+
+	func f() {
+		go func() { defer func() { recover() }(); work() }()
+		defer func(x int) { if x > 0 { go func() { select {} }() } }(1)
+		go func() {}()
+	}
+	`
+
+	expected := `package astpos
+
+func f() {
+	go func() {
+		defer func() {
+			recover()
+		}()
+		work()
+	}()
+	defer func(x int) {
+		if x > 0 {
+			go func() {
+				select {}
+			}()
+		}
+	}(1)
+	go func() {
+	}()
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if lit, ok := call.Fun.(*ast.FuncLit); ok && fset.Position(lit.End()).Line != fset.Position(call.Rparen).Line {
+				t.Errorf("The call at %s is not on the line of the closing brace", fset.Position(call.Lparen))
+			}
+		}
+		return true
+	})
+}
+
+func TestSpecDocComments(t *testing.T) {
+	f := parseSource(t, `package astpos
+
+	var hello string
+	`)
+	// An ordinary doc comment without directives stays the doc
+	// of its spec, which requires a parenthesized declaration
+	spec := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	spec.Doc = &ast.CommentGroup{List: []*ast.Comment{{Text: "// hello is greeted"}}}
+
+	expected := `package astpos
+
+var (
+	// hello is greeted
+	hello string
+)
+`
+
+	f, fset := RewritePositions(f)
+	got := writeAST(t, f, fset)
+	checkResult(t, got, expected)
+
+	parsed := parseSource(t, got)
+	if parsed.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Doc == nil {
+		t.Errorf("the doc comment is not attached to its spec")
+	}
+}
+
+func TestRewriteNode(t *testing.T) {
+	fn := &ast.FuncDecl{
+		Doc:  &ast.CommentGroup{List: []*ast.Comment{{Text: "// Greet prints a greeting"}}},
+		Name: ast.NewIdent("Greet"),
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.ExprStmt{X: &ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: ast.NewIdent("fmt"), Sel: ast.NewIdent("Println")},
+				Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: `"hello"`}},
+			}},
+		}},
+	}
+
+	expected := `// Greet prints a greeting
+func Greet() {
+	fmt.Println("hello")
+}`
+
+	n, fset := RewriteNode(fn)
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, n); err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, buf.String(), expected)
+
+	expr := &ast.BinaryExpr{X: ast.NewIdent("a"), Op: token.ADD, Y: ast.NewIdent("b")}
+	n, fset = RewriteNode(expr)
+	buf.Reset()
+	if err := printer.Fprint(&buf, fset, n); err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, buf.String(), "a + b")
+}
+
+func TestRewritePositionsInto(t *testing.T) {
+	srcA := `package astpos
+
+	// doc of A
+	var A = 1
+	`
+	srcB := `package astpos
+
+	func B() int {
+		return A
+	}
+	`
+
+	fset := token.NewFileSet()
+	fileA, fileB := parseSource(t, srcA), parseSource(t, srcB)
+	tokA := RewritePositionsInto(fset, fileA)
+	tokB := RewritePositionsInto(fset, fileB)
+
+	if fset.File(fileA.Pos()) != tokA || fset.File(fileB.Pos()) != tokB {
+		t.Fatal("the files are not registered in the shared FileSet")
+	}
+	if fileA.FileEnd >= fileB.FileStart {
+		t.Errorf("file A ends at %d after file B starts at %d", fileA.FileEnd, fileB.FileStart)
+	}
+	checkResult(t, writeAST(t, fileA, fset), "package astpos\n\n// doc of A\nvar A = 1\n")
+	checkResult(t, writeAST(t, fileB, fset), "package astpos\n\nfunc B() int {\n\treturn A\n}\n")
+}
+
+func TestRewritePackage(t *testing.T) {
+	files := map[string]*ast.File{
+		"b.go": parseSource(t, "package pkg\n\nfunc B() int {\n\treturn A\n}\n"),
+		"a.go": parseSource(t, "package pkg\n\nvar A = 1\n"),
+	}
+
+	fset, err := RewritePackage(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, f := range files {
+		if got := fset.File(f.Pos()).Name(); got != name {
+			t.Errorf("file %s is named %s in the FileSet", name, got)
+		}
+	}
+	if files["a.go"].FileEnd >= files["b.go"].FileStart {
+		t.Error("the files are not added in the order of their names")
+	}
+	checkResult(t, writeAST(t, files["a.go"], fset), "package pkg\n\nvar A = 1\n")
+	checkResult(t, writeAST(t, files["b.go"], fset), "package pkg\n\nfunc B() int {\n\treturn A\n}\n")
+
+	files["c.go"] = parseSource(t, "package other\n")
+	if _, err := RewritePackage(files); err == nil {
+		t.Error("expected an error for mixed package names")
+	}
+}
+
+func parseSource(t *testing.T, src string) *ast.File {
+	fset := token.NewFileSet()
+	opts := parser.SkipObjectResolution | parser.ParseComments
+	f, err := parser.ParseFile(fset, "x.go", src, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func checkResult(t *testing.T, result, expected string) {
+	t.Helper()
+	if result != expected {
+		t.Fatalf("The re-formatted source code differs from the expected outcome\n--- got ---\n%s\n--- expected ---\n%s", result, expected)
+	}
+}
+
+func writeAST(t *testing.T, f *ast.File, fset *token.FileSet) string {
+	formatted := &bytes.Buffer{}
+	if err := format.Node(formatted, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	importProcessed, err := imports.Process("", formatted.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(importProcessed)
+}
+
+// For debugging
+func saveToFile(code, filename string) {
+	out, err := os.Create(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.Close()
+
+	_, err = out.Write([]byte(code))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func TestIsNil(t *testing.T) {
+	var ident *ast.Ident
+	var expr ast.Expr = ident
+	cases := []struct {
+		node ast.Node
+		want bool
+	}{
+		{nil, true},
+		{expr, true},
+		{ast.NewIdent("x"), false},
+		{&ast.BlockStmt{}, false},
+		{placeholder{Ident: ast.NewIdent("x")}, false},
+		{pointerNode{}, false},
+		{(*pointerNode)(nil), true},
+	}
+	for _, c := range cases {
+		if got := isNil(c.node); got != c.want {
+			t.Errorf("isNil(%#v) = %v, expected %v", c.node, got, c.want)
+		}
+	}
+}
+
+// A custom node that holds nothing but a nil pointer
+type pointerNode struct{ *ast.Ident }
+
+func BenchmarkNilCheck(b *testing.B) {
+	f, err := parser.ParseFile(token.NewFileSet(), "x.go", sampleSource, parser.ParseComments)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var nodes []ast.Node
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n != nil {
+			nodes = append(nodes, n)
+		}
+		return true
+	})
+
+	nilNodes := 0
+	b.Run("reflect", func(b *testing.B) {
+		for range b.N {
+			for _, n := range nodes {
+				if v := reflect.ValueOf(n); v.Kind() == reflect.Ptr && v.IsNil() {
+					nilNodes++
+				}
+			}
+		}
+	})
+	b.Run("isNil", func(b *testing.B) {
+		for range b.N {
+			for _, n := range nodes {
+				if isNil(n) {
+					nilNodes++
+				}
+			}
+		}
+	})
+	if nilNodes > 0 {
+		b.Fatal("found nil nodes")
+	}
+}
+
+// Returns a large file made of many copies of the declarations of sampleSource
+func largeSource() string {
+	header := "package astpos\n\t\n"
+	decls := strings.TrimPrefix(sampleSource, header)
+	return header + strings.Repeat(decls, 100)
+}
+
+func BenchmarkMaxLineWidth(b *testing.B) {
+	// Deeply nested calls and a long chain of sums, whose subtrees
+	// are measured at every level
+	src := "package astpos\n\nvar x = " + strings.Repeat("f(a, ", 200) + strings.Repeat(")", 200) +
+		"\nvar y = a" + strings.Repeat(" + a", 1000) + "\n"
+	f, err := parser.ParseFile(token.NewFileSet(), "x.go", src, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for range b.N {
+		RewritePositions(f, WithMaxLineWidth(100))
+	}
+}
+
+func BenchmarkRewritePositions(b *testing.B) {
+	f, err := parser.ParseFile(token.NewFileSet(), "x.go", largeSource(), parser.ParseComments)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("RewritePositions", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			RewritePositions(f)
+		}
+	})
+	b.Run("Positioner", func(b *testing.B) {
+		b.ReportAllocs()
+		p := NewPositioner()
+		for range b.N {
+			p.RewritePositions(f)
+		}
+	})
 }
@@ -0,0 +1,66 @@
+// Package astpostest verifies the output of code generators that
+// build on astpos against golden files.
+package astpostest
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/snonky/astpos/astpos"
+)
+
+var update = flag.Bool("update", false, "update the golden files of astpostest.AssertFormats")
+
+// Formats the file with astpos.Format and compares the result with
+// the golden file. Running the tests with -update writes the result
+// to the golden file instead.
+func AssertFormats(t testing.TB, f *ast.File, goldenPath string, opts ...astpos.Option) {
+	t.Helper()
+	got, err := astpos.Format(f, opts...)
+	if err != nil {
+		t.Fatalf("Formatting failed: %v", err)
+	}
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(goldenPath, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("Reading the golden file failed (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Output differs from %s (run with -update to accept it):\n%s", goldenPath, diff(string(want), string(got)))
+	}
+}
+
+// Returns the lines from the first difference on
+func diff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	i := 0
+	for i < len(wantLines) && i < len(gotLines) && wantLines[i] == gotLines[i] {
+		i++
+	}
+
+	var b strings.Builder
+	for _, line := range wantLines[i:] {
+		b.WriteString("- " + line + "\n")
+	}
+	for _, line := range gotLines[i:] {
+		b.WriteString("+ " + line + "\n")
+	}
+	return "line " + strconv.Itoa(i+1) + ":\n" + b.String()
+}
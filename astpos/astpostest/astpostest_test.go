@@ -0,0 +1,24 @@
+package astpostest
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssertFormats(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "x.go", "package p; func f() { println(1) }", parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertFormats(t, f, filepath.Join("testdata", "format.golden"))
+}
+
+func TestDiff(t *testing.T) {
+	got := diff("a\nb\nc\n", "a\nx\nc\n")
+	expected := "line 2:\n- b\n- c\n- \n+ x\n+ c\n+ \n"
+	if got != expected {
+		t.Errorf("Unexpected diff:\n%s", got)
+	}
+}
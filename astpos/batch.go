@@ -0,0 +1,87 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+	"runtime"
+	"sync"
+)
+
+// Rewrites the positions of many independent files concurrently
+// into one shared FileSet, in which the files keep their order.
+// At most parallelism files are positioned at once. If it is less
+// than 1, runtime.GOMAXPROCS(0) is used.
+//
+// The options are shared by all goroutines, so a NodeHandler or
+// BasicLitRewriter must be safe for concurrent use.
+func RewriteAll(files []*ast.File, parallelism int, opts ...Option) *token.FileSet {
+	if parallelism < 1 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	options := newOptions(opts)
+
+	// The files are positioned in separate FileSets because
+	// their bases in the shared FileSet depend on the sizes
+	// of all preceding files
+	positioners := make([]*astPositioner, parallelism)
+	tmpFiles := make([]*token.File, len(files))
+	parallelFor(len(files), parallelism, func(worker, i int) {
+		if positioners[worker] == nil {
+			positioners[worker] = &astPositioner{opts: options}
+		}
+		p := positioners[worker]
+		p.init(files[i], token.NewFileSet())
+		p.positionTokens()
+		tmpFiles[i] = p.File
+	})
+
+	fset := token.NewFileSet()
+	bases := make([]int, len(files))
+	base := fset.Base()
+	for i, file := range tmpFiles {
+		bases[i] = base
+		base += file.Size() + 1
+	}
+
+	parallelFor(len(files), parallelism, func(_, i int) {
+		if delta := bases[i] - tmpFiles[i].Base(); delta != 0 {
+			shiftFile(files[i], delta)
+		}
+	})
+	for i, tmp := range tmpFiles {
+		file := fset.AddFile(tmp.Name(), bases[i], tmp.Size())
+		file.SetLines(tmp.Lines())
+	}
+	return fset
+}
+
+// Calls work for every index from 0 to n-1 on the given number of
+// goroutines. Each goroutine passes its own worker number.
+func parallelFor(n, parallelism int, work func(worker, i int)) {
+	var wg sync.WaitGroup
+	indices := make(chan int)
+	for worker := range min(parallelism, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				work(worker, i)
+			}
+		}()
+	}
+	for i := range n {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+}
+
+// Adds delta to all positions of the file including its comments
+func shiftFile(f *ast.File, delta int) {
+	shiftPositions(f, delta)
+	for _, c := range f.Comments {
+		for _, comment := range c.List {
+			comment.Slash += token.Pos(delta)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package astpos
+
+import (
+	"go/ast"
+	"testing"
+)
+
+func TestRewriteAll(t *testing.T) {
+	sources := []string{sampleSource, `package astpos
+
+	// Doc
+	func f() {
+		// Comment
+		g()
+	}
+	`}
+	var expected []string
+	for _, src := range sources {
+		f, fset := RewritePositions(parseSource(t, src))
+		expected = append(expected, writeAST(t, f, fset))
+	}
+
+	var files []*ast.File
+	for range 20 {
+		for _, src := range sources {
+			files = append(files, parseSource(t, src))
+		}
+	}
+	fset := RewriteAll(files, 4)
+
+	for i, f := range files {
+		if fset.File(f.Pos()) != fset.File(f.End()) {
+			t.Fatalf("File %d does not lie within one token.File", i)
+		}
+		if result := writeAST(t, f, fset); result != expected[i%len(sources)] {
+			t.Fatalf("File %d differs from the single file rewrite:\n%s", i, result)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package astpos
+
+import "go/ast"
+
+// Positions a chain of binary expressions with the same operator,
+// e.g. a && b && c, with one operand per line. The lines break after
+// the operators and the operands after the first one are indented
+// like go/printer indents them.
+func (p *astPositioner) wrappedChain(n *ast.BinaryExpr) {
+	// The parser nests chains to the left: (a && b) && c
+	chain := []*ast.BinaryExpr{n}
+	for {
+		x, ok := chain[0].X.(*ast.BinaryExpr)
+		if !ok || x.Op != n.Op {
+			break
+		}
+		chain = append([]*ast.BinaryExpr{x}, chain...)
+	}
+
+	p.traverse(chain[0].X)
+	p.indent++
+	for _, link := range chain {
+		p.space()
+		link.OpPos = p.pc()
+		p.move(link.Op)
+		p.newline()
+		p.traverse(link.Y)
+	}
+	p.indent--
+}
@@ -0,0 +1,60 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// Records the statements of the file that are followed by at least
+// one blank line while the file still has the positions it was
+// parsed with. The returned Option reproduces one blank line after
+// each of them, so hand-written code that is modified in between
+// keeps its paragraphs. The blank lines move with their statements.
+func PreserveBlankLines(f *ast.File, fset *token.FileSet) Option {
+	blankAfter := make(map[ast.Node]bool)
+	record := func(stmts []ast.Stmt) {
+		for i := 0; i < len(stmts)-1; i++ {
+			end := stmts[i].End()
+			next := nextStart(f, fset, end, stmts[i+1].Pos())
+			if fset.Position(next).Line-fset.Position(end).Line > 1 {
+				blankAfter[stmts[i]] = true
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			record(n.List)
+		case *ast.CaseClause:
+			record(n.Body)
+		case *ast.CommClause:
+			record(n.Body)
+		}
+		return true
+	})
+	return func(o *Options) {
+		o.BlankLinesAfter = blankAfter
+	}
+}
+
+// Returns the start of the first comment between end and next,
+// next if there is none. A blank line after a comment belongs
+// to the comment (see WithOriginalFileSet). Comments on the line
+// of end trail the statement and are skipped.
+func nextStart(f *ast.File, fset *token.FileSet, end, next token.Pos) token.Pos {
+	endLine := fset.Position(end).Line
+	for _, c := range f.Comments {
+		if c.Pos() >= end && c.Pos() < next && fset.Position(c.Pos()).Line > endLine {
+			return c.Pos()
+		}
+	}
+	return next
+}
+
+// Separates the node from the next one of its list by a blank line
+// if it was followed by one originally (see PreserveBlankLines)
+func (p *astPositioner) separateParagraph(n ast.Node) {
+	if p.opts.BlankLinesAfter[n] && p.index() < p.listSize()-1 {
+		p.blankLine()
+	}
+}
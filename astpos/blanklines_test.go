@@ -0,0 +1,100 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestPreserveBlankLines(t *testing.T) {
+	src := `package astpos
+
+func f() {
+	a := 1
+	b := 2
+
+
+	c := a + b
+
+	// comment
+
+	switch c {
+	case 3:
+		println(a)
+
+		println(b)
+	}
+	println(c)
+}
+`
+	expected := `package astpos
+
+func f() {
+	b := 2
+
+	a := 1
+	c := a + b
+
+	// comment
+
+	switch c {
+	case 3:
+		println(a)
+
+		println(b)
+	}
+	println(c)
+	println("new")
+}
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blankLines := PreserveBlankLines(f, fset)
+	anchors := ReanchorComments(f, fset)
+
+	// The blank line moves with its statement
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	body.List[0], body.List[1] = body.List[1], body.List[0]
+	newStmt := &ast.ExprStmt{X: MustParseExpr(`println("new")`)}
+	body.List = append(body.List, newStmt)
+
+	f, rewritten := RewritePositions(f, blankLines, anchors)
+	checkResult(t, writeAST(t, f, rewritten), expected)
+}
+
+func TestPreserveBlankLinesTrailingComment(t *testing.T) {
+	src := `package astpos
+
+func f() {
+	x := 1 // note
+
+	y := 2
+	println(x, y)
+}
+`
+	expected := `package astpos
+
+func f() {
+	x := 1
+
+	y := 2
+	println(x, y)
+}
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The trailing comment is dropped without the original FileSet,
+	// the blank line after its statement is kept
+	f, rewritten := RewritePositions(f, PreserveBlankLines(f, fset))
+	checkResult(t, writeAST(t, f, rewritten), expected)
+}
@@ -0,0 +1,64 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// Breaks chains of method calls with at least threshold calls, e.g.
+// of builder APIs, into one call per line. The lines break after the
+// dots and the first call stays on the line of the receiver:
+//
+//	q.Select("a").
+//		From("t").
+//		Limit(10)
+func WithChainThreshold(threshold int) Option {
+	return func(o *Options) {
+		o.ChainThreshold = threshold
+	}
+}
+
+// Returns the calls of the method chain that ends with the call,
+// innermost first, or nil if the chain is not broken into lines
+// (see WithChainThreshold)
+func (p *astPositioner) callChain(n *ast.CallExpr) []*ast.CallExpr {
+	if p.opts.ChainThreshold <= 0 || p.oneLine {
+		return nil
+	}
+	var links []*ast.CallExpr
+	for call := n; ; {
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			break
+		}
+		links = append(links, call)
+		if call, ok = sel.X.(*ast.CallExpr); !ok {
+			break
+		}
+	}
+	if len(links) < p.opts.ChainThreshold || len(links) < 2 {
+		return nil
+	}
+	for i, j := 0, len(links)-1; i < j; i, j = i+1, j-1 {
+		links[i], links[j] = links[j], links[i]
+	}
+	return links
+}
+
+// Positions the calls of a method chain one per line. The arguments
+// of the calls are only split by HintExpand.
+func (p *astPositioner) wrappedCallChain(links []*ast.CallExpr) {
+	p.traverse(links[0].Fun.(*ast.SelectorExpr).X)
+	for i, call := range links {
+		p.move(token.PERIOD)
+		if i == 1 {
+			p.indent++
+		}
+		if i > 0 {
+			p.newline()
+		}
+		p.traverse(call.Fun.(*ast.SelectorExpr).Sel)
+		p.callArgs(call, p.hasHint(call, HintExpand))
+	}
+	p.indent--
+}
@@ -0,0 +1,34 @@
+package astpos
+
+import "testing"
+
+func TestChainThreshold(t *testing.T) {
+	src := `package astpos
+
+	func f() {
+		rows := db.Select("a", "b").From("t").Where("x = ?", 1).Limit(10)
+		b.WriteString("x").Reset()
+		s := repl.For("a", "b").Replace(in).Len()
+		_ = New().Name("n").Build()
+	}
+	`
+
+	expected := `package astpos
+
+func f() {
+	rows := db.Select("a", "b").
+		From("t").
+		Where("x = ?", 1).
+		Limit(10)
+	b.WriteString("x").Reset()
+	s := repl.For("a", "b").
+		Replace(in).
+		Len()
+	_ = New().Name("n").Build()
+}
+`
+
+	// New is a function, not a method, so its chain has two calls
+	f, fset := RewritePositions(parseSource(t, src), WithChainThreshold(3))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
@@ -0,0 +1,39 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+)
+
+// Sets all position fields of the node and its children, including
+// comments, to token.NoPos. Parsed nodes that are mixed into a
+// generated tree would otherwise keep stale positions, which confuse
+// go/format even after a rewrite of the surrounding nodes.
+func ClearPositions(n ast.Node) {
+	posType := reflect.TypeOf(token.NoPos)
+	ast.Inspect(n, func(n ast.Node) bool {
+		if n == nil || isNil(n) {
+			return false
+		}
+		v := reflect.ValueOf(n)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			return true
+		}
+		v = v.Elem()
+		for i := range v.NumField() {
+			if field := v.Field(i); field.Type() == posType && field.CanSet() {
+				field.SetInt(int64(token.NoPos))
+			}
+		}
+		return true
+	})
+	if f, ok := n.(*ast.File); ok {
+		// Free floating comments are not children of any node
+		for _, c := range f.Comments {
+			for _, comment := range c.List {
+				comment.Slash = token.NoPos
+			}
+		}
+	}
+}
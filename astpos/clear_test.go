@@ -0,0 +1,36 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestClearPositions(t *testing.T) {
+	f := parseSource(t, sampleSource)
+	ClearPositions(f)
+
+	posType := reflect.TypeOf(token.NoPos)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		v := reflect.ValueOf(n).Elem()
+		for i := range v.NumField() {
+			if field := v.Field(i); field.Type() == posType && field.Int() != int64(token.NoPos) {
+				t.Errorf("%T.%s is not cleared", n, v.Type().Field(i).Name)
+			}
+		}
+		return true
+	})
+	for _, c := range f.Comments {
+		if c.Pos().IsValid() {
+			t.Errorf("Comment %q is not cleared", c.Text())
+		}
+	}
+
+	f, fset := RewritePositions(f)
+	expected, fset2 := RewritePositions(parseSource(t, sampleSource))
+	checkResult(t, writeAST(t, f, fset), writeAST(t, expected, fset2))
+}
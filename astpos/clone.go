@@ -0,0 +1,82 @@
+package astpos
+
+import (
+	"go/ast"
+	"reflect"
+)
+
+// Returns a deep copy of the node with all positions set to
+// token.NoPos, so a parsed snippet can be used several times in
+// one file. Nodes and comment groups that are shared within the
+// tree stay shared in the copy (e.g. the doc comments in
+// File.Comments). The deprecated *ast.Object and *ast.Scope
+// references are not copied.
+func Clone[T ast.Node](n T) T {
+	if isNil(n) {
+		return n
+	}
+	c := cloner{copies: make(map[pointerKey]reflect.Value)}
+	clone := c.value(reflect.ValueOf(n)).Interface().(T)
+	ClearPositions(clone)
+	return clone
+}
+
+type cloner struct {
+	// Copies of the pointers that were already cloned
+	copies map[pointerKey]reflect.Value
+}
+
+// Identifies a pointer by its target. The type tells apart a struct
+// and its first field, which share the address.
+type pointerKey struct {
+	typ  reflect.Type
+	addr uintptr
+}
+
+var (
+	objectType = reflect.TypeOf((*ast.Object)(nil))
+	scopeType  = reflect.TypeOf((*ast.Scope)(nil))
+)
+
+func (c *cloner) value(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || v.Type() == objectType || v.Type() == scopeType {
+			return reflect.Zero(v.Type())
+		}
+		key := pointerKey{v.Type(), v.Pointer()}
+		if copy, ok := c.copies[key]; ok {
+			return copy
+		}
+		copy := reflect.New(v.Type().Elem())
+		c.copies[key] = copy
+		copy.Elem().Set(c.value(v.Elem()))
+		return copy
+	case reflect.Interface:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		copy := reflect.New(v.Type()).Elem()
+		copy.Set(c.value(v.Elem()))
+		return copy
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		copy := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			copy.Index(i).Set(c.value(v.Index(i)))
+		}
+		return copy
+	case reflect.Struct:
+		copy := reflect.New(v.Type()).Elem()
+		copy.Set(v)
+		for i := range v.NumField() {
+			if field := copy.Field(i); field.CanSet() {
+				field.Set(c.value(v.Field(i)))
+			}
+		}
+		return copy
+	}
+	return v
+}
@@ -0,0 +1,63 @@
+package astpos
+
+import (
+	"go/ast"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	f := parseSource(t, sampleSource)
+	clone := Clone(f)
+
+	original := make(map[ast.Node]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n != nil {
+			original[n] = true
+		}
+		return true
+	})
+	ast.Inspect(clone, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if original[n] {
+			t.Errorf("%T is shared with the original", n)
+		}
+		if n.Pos().IsValid() {
+			t.Errorf("%T has a position", n)
+		}
+		return true
+	})
+	if clone.Doc != nil && clone.Comments[0] != clone.Doc {
+		t.Error("Doc comment is not shared with Comments")
+	}
+
+	// The original is left intact
+	expected, fset := RewritePositions(parseSource(t, sampleSource))
+	clone, cloneFset := RewritePositions(clone)
+	checkResult(t, writeAST(t, clone, cloneFset), writeAST(t, expected, fset))
+}
+
+func TestCloneSharedComments(t *testing.T) {
+	src := "// Doc\npackage p\n\n// F doc\nfunc F() {}\n"
+	clone := Clone(parseSource(t, src))
+	if clone.Doc == nil || clone.Doc != clone.Comments[0] {
+		t.Error("file doc comment is not shared with Comments")
+	}
+	if fn := clone.Decls[0].(*ast.FuncDecl); fn.Doc == nil || fn.Doc != clone.Comments[1] {
+		t.Error("declaration doc comment is not shared with Comments")
+	}
+	clone, fset := RewritePositions(clone)
+	checkResult(t, writeAST(t, clone, fset), "// Doc\npackage p\n\n// F doc\nfunc F() {\n}\n")
+}
+
+func TestCloneSnippet(t *testing.T) {
+	f := parseSource(t, "package main\n\nfunc f() {\n\tx := 1\n}\n")
+	stmt := f.Decls[0].(*ast.FuncDecl).Body.List[0]
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	body.List = append(body.List, Clone(stmt), Clone(stmt))
+	ClearPositions(f)
+
+	f, fset := RewritePositions(f)
+	checkResult(t, writeAST(t, f, fset), "package main\n\nfunc f() {\n\tx := 1\n\tx := 1\n\tx := 1\n}\n")
+}
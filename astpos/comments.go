@@ -0,0 +1,311 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// A free floating comment group together with its original
+// distance to the neighboring nodes
+type floatingComment struct {
+	group *ast.CommentGroup
+
+	blankBefore, blankAfter bool
+
+	// Originally indented deeper than the token that follows it
+	indent bool
+}
+
+// Anchors of the free floating comments, keyed by the node
+// they precede, follow on the same line or end (as container)
+type floatingComments struct {
+	before, after, end map[ast.Node][]floatingComment
+}
+
+// Comments of a file anchored to its nodes (see ReanchorComments)
+type CommentAnchors struct {
+	packageComment *ast.CommentGroup
+	headerComments []*ast.CommentGroup
+	floating       floatingComments
+}
+
+// Anchors all comments of the file that are not doc or line comments
+// of a node (free floating comments and end of line comments of
+// statements) to their neighboring nodes while the file still has
+// the positions it was parsed with.
+// The returned Option carries the anchored comments through
+// RewritePositions, so the file can be modified in between, e.g. by
+// inserting new nodes without positions or moving statements.
+// Comments anchored to removed nodes are dropped.
+func ReanchorComments(f *ast.File, origFset *token.FileSet) Option {
+	p := &astPositioner{file: f, opts: Options{OriginalFileSet: origFset}}
+	p.packageComment = findPackageComment(f, origFset)
+	p.headerComments = findHeaderComments(f)
+	p.collectFloatingComments()
+	anchors := &CommentAnchors{
+		packageComment: p.packageComment,
+		headerComments: p.headerComments,
+		floating:       p.floating,
+	}
+	return func(o *Options) {
+		o.CommentAnchors = anchors
+	}
+}
+
+// Assigns each comment group that is not handled as a doc or line
+// comment to the list element (declaration, statement, spec, field
+// or case clause) it belongs to.
+// Must be called before the positions are rewritten.
+func (p *astPositioner) collectFloatingComments() {
+	p.floating = floatingComments{
+		before: make(map[ast.Node][]floatingComment),
+		after:  make(map[ast.Node][]floatingComment),
+		end:    make(map[ast.Node][]floatingComment),
+	}
+
+	attached := attachedComments(p.file)
+	if p.packageComment != nil {
+		attached[p.packageComment] = true
+	}
+	for _, c := range p.headerComments {
+		attached[c] = true
+	}
+	for i, c := range p.file.Comments {
+		if attached[c] || !c.Pos().IsValid() || p.inUnchangedDecl(c) {
+			continue
+		}
+		// Neighboring comments count like nodes for the blank lines
+		prevEnd, nextStart := token.NoPos, token.NoPos
+		if i > 0 {
+			prevEnd = p.file.Comments[i-1].End()
+		}
+		if i < len(p.file.Comments)-1 {
+			nextStart = p.file.Comments[i+1].Pos()
+		}
+		container, children := p.commentContainer(c)
+		p.anchorComment(c, container, children, prevEnd, nextStart)
+	}
+}
+
+// Anchors the comment group to its neighbors in the list of children
+func (p *astPositioner) anchorComment(c *ast.CommentGroup, container ast.Node, children []ast.Node, prevEnd, nextStart token.Pos) {
+	line := func(pos token.Pos) int {
+		return p.opts.OriginalFileSet.Position(pos).Line
+	}
+
+	var prev, next ast.Node
+	for _, child := range children {
+		start, end := nodeStart(child), child.End()
+		if !start.IsValid() || !end.IsValid() {
+			continue
+		}
+		if start <= c.Pos() && c.End() <= end {
+			// Inside of a child but not attached to any of its nodes
+			p.floating.after[child] = append(p.floating.after[child], floatingComment{group: c})
+			return
+		}
+		if end <= c.Pos() {
+			prev = child
+		}
+		if next == nil && start >= c.End() {
+			next = child
+		}
+	}
+
+	// Only neighbors inside of the container
+	if nextStart >= container.End() {
+		nextStart = token.NoPos
+	}
+	if prevEnd < container.Pos() {
+		prevEnd = token.NoPos
+	}
+	if next != nil && (!nextStart.IsValid() || nodeStart(next) < nextStart) {
+		nextStart = nodeStart(next)
+	}
+	if prev != nil && prev.End() > prevEnd {
+		prevEnd = prev.End()
+	}
+
+	fc := floatingComment{group: c}
+	if nextStart.IsValid() {
+		fc.blankAfter = line(nextStart)-line(c.End()) > 1
+	}
+	if prev != nil {
+		if line(prev.End()) == line(c.Pos()) {
+			p.floating.after[prev] = append(p.floating.after[prev], fc)
+			return
+		}
+	}
+	if prevEnd.IsValid() {
+		fc.blankBefore = line(c.Pos())-line(prevEnd) > 1
+	}
+	if next != nil {
+		fc.indent = p.indentedBefore(c, nodeStart(next))
+		p.floating.before[next] = append(p.floating.before[next], fc)
+		return
+	}
+	fc.indent = p.indentedBefore(c, closingPos(container))
+	fc.blankAfter = false
+	p.floating.end[container] = append(p.floating.end[container], fc)
+}
+
+// Returns true if the comment group is indented deeper than the token
+// at the given position. go/printer decides on the indentation of the
+// comment by the same comparison.
+func (p *astPositioner) indentedBefore(c *ast.CommentGroup, pos token.Pos) bool {
+	if !pos.IsValid() {
+		return false
+	}
+	return p.opts.OriginalFileSet.Position(c.Pos()).Column > p.opts.OriginalFileSet.Position(pos).Column
+}
+
+// Returns the position of the token that closes the container
+func closingPos(container ast.Node) token.Pos {
+	switch n := container.(type) {
+	case *ast.BlockStmt:
+		return n.Rbrace
+	case *ast.CaseClause:
+		return n.Case
+	case *ast.CommClause:
+		return n.Case
+	case *ast.FieldList:
+		return n.Closing
+	case *ast.GenDecl:
+		return n.Rparen
+	}
+	return token.NoPos
+}
+
+// Returns the innermost node that holds a list of line based
+// children (declarations, statements, ...) around the comment group
+func (p *astPositioner) commentContainer(c *ast.CommentGroup) (ast.Node, []ast.Node) {
+	var container ast.Node = p.file
+	children := nodeList(p.file.Decls)
+	ast.Inspect(p.file, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if _, ok := n.(*ast.File); !ok && (n.Pos() > c.Pos() || n.End() < c.End()) {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			container, children = n, nodeList(n.List)
+		case *ast.CaseClause:
+			container, children = n, nodeList(n.Body)
+		case *ast.CommClause:
+			container, children = n, nodeList(n.Body)
+		case *ast.FieldList:
+			container, children = n, nodeList(n.List)
+		case *ast.GenDecl:
+			if n.Lparen.IsValid() {
+				container, children = n, nodeList(n.Specs)
+			}
+		}
+		return true
+	})
+	return container, children
+}
+
+// Positions the free floating comments that precede the node
+func (p *astPositioner) handleFloatingBefore(n ast.Node) {
+	for _, fc := range p.floating.before[n] {
+		p.handleFloatingComment(fc)
+	}
+}
+
+// Positions the free floating comments at the end of the line of the node
+func (p *astPositioner) handleFloatingAfter(n ast.Node) {
+	for _, fc := range p.floating.after[n] {
+		p.handleLineComment(fc.group)
+		if fc.blankAfter {
+			p.newline()
+		}
+	}
+}
+
+// Positions the free floating comments at the end of a container
+func (p *astPositioner) handleFloatingEnd(n ast.Node) {
+	for _, fc := range p.floating.end[n] {
+		p.handleFloatingComment(fc)
+	}
+}
+
+func (p *astPositioner) handleFloatingComment(fc floatingComment) {
+	if fc.blankBefore {
+		p.endLine()
+		p.newline()
+	}
+	indent := ""
+	if fc.indent {
+		indent = " "
+	}
+	p.handleIndentedComment(fc.group, indent)
+	if fc.blankAfter {
+		p.newline()
+	}
+}
+
+// Returns the set of comment groups that are positioned as doc or
+// line comments of their nodes
+func attachedComments(f *ast.File) map[*ast.CommentGroup]bool {
+	attached := make(map[*ast.CommentGroup]bool)
+	add := func(groups ...*ast.CommentGroup) {
+		for _, c := range groups {
+			if c != nil {
+				attached[c] = true
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			add(n.Doc, n.Comment)
+		case *ast.File:
+			add(n.Doc)
+		case *ast.FuncDecl:
+			add(n.Doc)
+		case *ast.GenDecl:
+			add(n.Doc)
+		case *ast.ImportSpec:
+			add(n.Doc, n.Comment)
+		case *ast.TypeSpec:
+			add(n.Doc)
+		case *ast.ValueSpec:
+			add(n.Doc, n.Comment)
+		}
+		return true
+	})
+	return attached
+}
+
+// Returns the start of a node including its doc comment
+func nodeStart(n ast.Node) token.Pos {
+	var doc *ast.CommentGroup
+	switch n := n.(type) {
+	case *ast.Field:
+		doc = n.Doc
+	case *ast.FuncDecl:
+		doc = n.Doc
+	case *ast.GenDecl:
+		doc = n.Doc
+	case *ast.ImportSpec:
+		doc = n.Doc
+	case *ast.TypeSpec:
+		doc = n.Doc
+	case *ast.ValueSpec:
+		doc = n.Doc
+	}
+	if doc != nil {
+		return doc.Pos()
+	}
+	return n.Pos()
+}
+
+func nodeList[Slice ~[]E, E ast.Node](nodes Slice) []ast.Node {
+	list := make([]ast.Node, len(nodes))
+	for i, n := range nodes {
+		list[i] = n
+	}
+	return list
+}
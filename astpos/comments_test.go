@@ -0,0 +1,157 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestFloatingComments(t *testing.T) {
+	src := `package astpos
+
+	// floating at the top
+
+	// doc comment
+	var A = 1
+
+	// floating between declarations
+
+	type T int // trailing on a type
+
+	func f() {
+		x := 1 // trailing on a statement
+		// before y
+
+		y := 2
+
+		// section two
+		_ = x + y
+		switch x {
+		case 1:
+			println()
+			// end of case
+		}
+		// end of block
+	}
+
+	var (
+		a = 1
+		// end of var block
+	)
+
+	// end of file
+	`
+
+	expected := `package astpos
+
+// floating at the top
+
+// doc comment
+var A = 1
+
+// floating between declarations
+
+type T int // trailing on a type
+
+func f() {
+	x := 1 // trailing on a statement
+	// before y
+
+	y := 2
+
+	// section two
+	_ = x + y
+	switch x {
+	case 1:
+		println()
+		// end of case
+	}
+	// end of block
+}
+
+var (
+	a = 1
+	// end of var block
+)
+
+// end of file
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, fset = RewritePositions(f, WithOriginalFileSet(fset))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestFloatingCommentsWithoutFileSet(t *testing.T) {
+	src := `package astpos
+
+	func f() {
+		// dropped
+		x := 1
+		_ = x
+	}
+	`
+
+	expected := `package astpos
+
+func f() {
+	x := 1
+	_ = x
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestReanchorComments(t *testing.T) {
+	src := `package astpos
+
+	func f() {
+		// first
+		a := 1
+		b := 2 // second
+
+		// third
+		_ = a + b
+	}
+	`
+
+	expected := `package astpos
+
+func f() {
+	b := 2 // second
+
+	// first
+	a := 1
+
+	// third
+	_ = a + b
+	println()
+}
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anchors := ReanchorComments(f, fset)
+
+	// The comments move with their statements
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	body.List[0], body.List[1] = body.List[1], body.List[0]
+	body.List = append(body.List, &ast.ExprStmt{X: &ast.CallExpr{Fun: ast.NewIdent("println")}})
+
+	f, rewritten := RewritePositions(f, anchors)
+	checkResult(t, writeAST(t, f, rewritten), expected)
+
+	// The original positions are gone but the anchors still apply
+	f, rewritten = RewritePositions(f, anchors)
+	checkResult(t, writeAST(t, f, rewritten), expected)
+}
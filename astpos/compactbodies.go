@@ -0,0 +1,82 @@
+package astpos
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+)
+
+// Line width up to which go/printer keeps function bodies on the
+// line of their signature
+const maxOneLineBody = 100
+
+// Keeps function bodies with a single statement on the line of their
+// signature, e.g. func (t T) Len() int { return len(t) }, if the line
+// does not exceed the width. go/printer never keeps bodies that exceed
+// 100 columns or the blocks of if statements and loops on one line.
+func WithCompactBodies(width int) Option {
+	return func(o *Options) {
+		o.CompactBodyWidth = width
+	}
+}
+
+// Marks the body of the function declaration as the next function
+// body and decides whether it is kept on one line
+func (p *astPositioner) declBody(n *ast.FuncDecl) {
+	p.funcBody = n.Body
+	if p.opts.CompactBodyWidth > 0 {
+		header := *n
+		header.Doc, header.Body = nil, nil
+		p.isCompact = p.isCompactBody(&header, n.Body)
+	}
+}
+
+// Same as declBody for the body of a function literal
+func (p *astPositioner) litBody(n *ast.FuncLit) {
+	p.funcBody = n.Body
+	if p.opts.CompactBodyWidth > 0 {
+		p.isCompact = p.isCompactBody(n.Type, n.Body)
+	}
+}
+
+// Returns true if the function body with the header of the given
+// node (the declaration without body or the type of a literal) is
+// kept on one line (see WithCompactBodies)
+func (p *astPositioner) isCompactBody(header ast.Node, body *ast.BlockStmt) bool {
+	if p.opts.CompactBodyWidth <= 0 || len(body.List) != 1 || p.hasFloating(body) || p.hasFloating(body.List[0]) {
+		return false
+	}
+	headerSize := oneLineSize(header)
+	stmtSize := oneLineSize(body.List[0])
+	if headerSize < 0 || stmtSize < 0 || headerSize+stmtSize > maxOneLineBody {
+		return false
+	}
+	return headerSize+len(" { ")+stmtSize+len(" }") <= p.opts.CompactBodyWidth
+}
+
+// Returns true if free floating comments are positioned around the
+// node or at its end
+func (p *astPositioner) hasFloating(n ast.Node) bool {
+	return len(p.floating.before[n]) > 0 || len(p.floating.after[n]) > 0 || len(p.floating.end[n]) > 0
+}
+
+// Returns the length of the node printed without positions,
+// -1 if it spans several lines
+func oneLineSize(n ast.Node) int {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, token.NewFileSet(), n); err != nil || bytes.ContainsRune(buf.Bytes(), '\n') {
+		return -1
+	}
+	return buf.Len()
+}
+
+// Positions a function body on one line
+func (p *astPositioner) compactBody(body *ast.BlockStmt) {
+	oneLine := p.oneLine
+	p.oneLine = true
+	p.space()
+	traverseList(p, body.List)
+	p.oneLine = oneLine
+	p.space()
+}
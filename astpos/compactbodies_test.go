@@ -0,0 +1,78 @@
+package astpos
+
+import (
+	"go/ast"
+	"testing"
+)
+
+func TestCompactBodies(t *testing.T) {
+	src := `package astpos
+
+	func (t T) Len() int { return len(t) }
+
+	func (t T) Less(i, j int) bool {
+		return t[i] < t[j]
+	}
+
+	func (t T) Swap(i, j int) {
+		t[i], t[j] = t[j], t[i]
+	}
+
+	func (t T) String() string {
+		return sprintf("a rather long format string %v that exceeds the width", t)
+	}
+
+	func (t T) Each(f func(int)) {
+		for _, x := range t {
+			f(x)
+		}
+	}
+
+	func (t T) Adder() func(int) int {
+		return func(x int) int { return x + len(t) }
+	}
+
+	func (t T) Pair() (int, int) {
+		t.Reset()
+		return 0, 0
+	}
+	`
+
+	expected := `package astpos
+
+func (t T) Len() int { return len(t) }
+
+func (t T) Less(i, j int) bool { return t[i] < t[j] }
+
+func (t T) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+
+func (t T) String() string {
+	return sprintf("a rather long format string %v that exceeds the width", t)
+}
+
+func (t T) Each(f func(int)) {
+	for _, x := range t {
+		f(x)
+	}
+}
+
+func (t T) Adder() func(int) int {
+	return func(x int) int { return x + len(t) }
+}
+
+func (t T) Pair() (int, int) {
+	t.Reset()
+	return 0, 0
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src), WithCompactBodies(60))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	for _, decl := range f.Decls[:3] {
+		fn := decl.(*ast.FuncDecl)
+		if fset.Position(fn.Body.Rbrace).Line != fset.Position(fn.Pos()).Line {
+			t.Errorf("The body of %s is not on the line of its signature", fn.Name.Name)
+		}
+	}
+}
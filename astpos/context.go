@@ -0,0 +1,40 @@
+package astpos
+
+// Kind of the field list around a node, which decides how its
+// fields are laid out (see Positioner.Context)
+type Context int
+
+const (
+	// Outside of any field list
+	ContextNone Context = iota
+	// Fields of a struct type, one per line
+	ContextStruct
+	// Methods and embedded elements of an interface type
+	ContextInterface
+	// Parameters and results of a function type
+	ContextParams
+	// Type parameters of a function or type
+	ContextTypeParams
+)
+
+// Returns the kind of the innermost field list around the node
+// that is being positioned, e.g. to lay out a custom node in a
+// NodeHandler depending on where it is used
+func (p *Positioner) Context() Context {
+	return p.p.context()
+}
+
+func (p *astPositioner) pushContext(c Context) {
+	p.contextStack = append(p.contextStack, c)
+}
+
+func (p *astPositioner) popContext() {
+	p.contextStack = p.contextStack[:len(p.contextStack)-1]
+}
+
+func (p *astPositioner) context() Context {
+	if len(p.contextStack) == 0 {
+		return ContextNone
+	}
+	return p.contextStack[len(p.contextStack)-1]
+}
@@ -0,0 +1,115 @@
+package astpos
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// Width of the lines of DocComment including the "// " prefix
+const DefaultDocWidth = 80
+
+// Same as DocCommentWidth with the DefaultDocWidth
+func DocComment(text string) *ast.CommentGroup {
+	return DocCommentWidth(text, DefaultDocWidth)
+}
+
+// Returns the text as a doc comment that can be set as the Doc of a
+// node. The paragraphs of the text, separated by empty lines, are
+// word-wrapped to lines of at most width characters including the
+// "// " prefix, words longer than a line are not split. Indented
+// lines are kept as they are, e.g. code blocks.
+// The positions of the comments are set by the rewrite.
+func DocCommentWidth(text string, width int) *ast.CommentGroup {
+	var lines []string
+	var paragraph []string
+	flush := func() {
+		lines = append(lines, wrapWords(paragraph, width-len("// "))...)
+		paragraph = paragraph[:0]
+	}
+	for _, line := range strings.Split(strings.Trim(text, "\n"), "\n") {
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+			lines = append(lines, "")
+		case line[0] == ' ' || line[0] == '\t':
+			flush()
+			lines = append(lines, "\t"+strings.TrimLeft(line, " \t"))
+		default:
+			paragraph = append(paragraph, strings.Fields(line)...)
+		}
+	}
+	flush()
+
+	c := &ast.CommentGroup{List: make([]*ast.Comment, len(lines))}
+	for i, line := range lines {
+		switch {
+		case line == "":
+			line = "//"
+		case line[0] == '\t':
+			line = "//" + line
+		default:
+			line = "// " + line
+		}
+		c.List[i] = &ast.Comment{Text: line}
+	}
+	return c
+}
+
+// Returns a "Deprecated:" paragraph with the reason, which godoc and
+// linters recognize when it is a paragraph of its own (see JoinDocs)
+func Deprecated(reason string) *ast.CommentGroup {
+	return DocComment("Deprecated: " + reason)
+}
+
+// Returns the code as an indented code block, which godoc shows
+// preformatted. The indentation of the lines is kept relative to
+// the block.
+func Example(code string) *ast.CommentGroup {
+	lines := strings.Split(strings.Trim(code, "\n"), "\n")
+	c := &ast.CommentGroup{List: make([]*ast.Comment, len(lines))}
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line != "" {
+			line = "\t" + line
+		}
+		c.List[i] = &ast.Comment{Text: "//" + line}
+	}
+	return c
+}
+
+// Joins the comment groups to one doc comment with the groups as
+// paragraphs, e.g. a DocComment followed by an Example and a
+// Deprecated notice. Nil groups are skipped.
+func JoinDocs(groups ...*ast.CommentGroup) *ast.CommentGroup {
+	doc := &ast.CommentGroup{}
+	for _, c := range groups {
+		if c == nil {
+			continue
+		}
+		if len(doc.List) > 0 {
+			doc.List = append(doc.List, &ast.Comment{Text: "//"})
+		}
+		doc.List = append(doc.List, c.List...)
+	}
+	return doc
+}
+
+// Joins the words to lines of at most width characters
+func wrapWords(words []string, width int) []string {
+	var lines []string
+	var line strings.Builder
+	for _, word := range words {
+		if line.Len() > 0 && line.Len()+1+len(word) > width {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		if line.Len() > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(word)
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
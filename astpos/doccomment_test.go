@@ -0,0 +1,84 @@
+package astpos
+
+import (
+	"go/ast"
+	"testing"
+)
+
+func TestDocComment(t *testing.T) {
+	src := `package astpos
+
+	func f() {}
+
+	var x = 1
+	`
+	expected := `package astpos
+
+// f does things that take a few words
+// to describe.
+//
+// Call it like this:
+//
+//	f()
+func f() {
+}
+
+// x is one.
+var x = 1
+`
+
+	f := parseSource(t, src)
+	f.Decls[0].(*ast.FuncDecl).Doc = DocCommentWidth(`
+f does things that take a
+few words to describe.
+
+Call it like this:
+
+	f()
+`, 40)
+	f.Decls[1].(*ast.GenDecl).Doc = DocComment("x is one.")
+	f, fset := RewritePositions(f)
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestWrapWords(t *testing.T) {
+	lines := wrapWords([]string{"a", "bb", "averyveryverylongword", "c"}, 6)
+	expected := []string{"a bb", "averyveryverylongword", "c"}
+	if len(lines) != len(expected) {
+		t.Fatalf("Unexpected lines %q", lines)
+	}
+	for i := range lines {
+		if lines[i] != expected[i] {
+			t.Errorf("Line %d is %q, expected %q", i, lines[i], expected[i])
+		}
+	}
+}
+
+func TestDocHelpers(t *testing.T) {
+	src := `package astpos
+
+	func Old() {}
+	`
+	expected := `package astpos
+
+// Old does the old thing.
+//
+//	if ok {
+//		Old()
+//	}
+//
+// Deprecated: Use New instead.
+func Old() {
+}
+`
+
+	f := parseSource(t, src)
+	f.Decls[0].(*ast.FuncDecl).Doc = JoinDocs(
+		DocComment("Old does the old thing."),
+		Example("if ok {\n\tOld()\n}"),
+		nil,
+		Deprecated("Use New instead."),
+	)
+	f, fset := RewritePositions(f)
+	checkResult(t, writeAST(t, f, fset), expected)
+}
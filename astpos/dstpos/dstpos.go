@@ -0,0 +1,67 @@
+// Package dstpos converts decorated syntax trees of github.com/dave/dst
+// into go/ast files positioned by astpos. It is a package of its own,
+// so that astpos does not depend on dst.
+package dstpos
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/snonky/astpos/astpos"
+)
+
+// Converts the file into an *ast.File with the positions assigned by
+// astpos.RewritePositions. The comments of the decorations stay at
+// their nodes and the empty lines between statements are kept (see
+// astpos.PreserveBlankLines). Blank lines between declarations follow
+// the options (see astpos.WithBlankLines).
+// Returns an error for files decorated with import management (see
+// decorator.NewDecoratorWithImports), which are not supported.
+func FromDst(f *dst.File, opts ...astpos.Option) (*ast.File, *token.FileSet, error) {
+	if err := checkImportPaths(f); err != nil {
+		return nil, nil, err
+	}
+	restorer := decorator.NewRestorer()
+	file, err := restorer.RestoreFile(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	restoreFileDoc(file, restorer.Fset)
+	opts = append([]astpos.Option{
+		astpos.WithOriginalFileSet(restorer.Fset),
+		astpos.PreserveBlankLines(file, restorer.Fset),
+	}, opts...)
+	file, fset := astpos.RewritePositions(file, opts...)
+	return file, fset, nil
+}
+
+// Returns an error for identifiers with an import path, which the
+// restorer without import management panics on
+func checkImportPaths(f *dst.File) error {
+	var err error
+	dst.Inspect(f, func(n dst.Node) bool {
+		if id, ok := n.(*dst.Ident); ok && id.Path != "" && err == nil {
+			err = fmt.Errorf("dstpos: %s.%s is decorated with import management, which is not supported", id.Path, id.Name)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// Sets the doc comment of the file, which the restorer of dst leaves
+// empty, to the comment group directly above the package clause.
+// Otherwise it would be positioned as a header comment.
+func restoreFileDoc(f *ast.File, fset *token.FileSet) {
+	line := fset.Position(f.Package).Line
+	for _, c := range f.Comments {
+		if c.Pos() > f.Package {
+			return
+		}
+		if line-fset.Position(c.End()).Line <= 1 {
+			f.Doc = c
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package dstpos
+
+import (
+	"bytes"
+	"go/format"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+)
+
+func TestFromDst(t *testing.T) {
+	src := `// Copyright header
+
+// Package p is decorated
+package p
+
+// F does things
+func F() {
+	a := 1 // one
+
+	// b follows after an empty line
+	b := a
+	_ = b
+}
+`
+
+	expected := `// Copyright header
+
+// Package p is decorated
+package p
+
+// F does things
+func F() {
+	a := 1 // one
+
+	// b follows after an empty line
+	b := a
+	_ = b
+
+	// Added by the generator
+	G()
+}
+`
+
+	f, err := decorator.Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := &dst.ExprStmt{X: &dst.CallExpr{Fun: dst.NewIdent("G")}}
+	call.Decs.Before = dst.EmptyLine
+	call.Decs.Start.Append("// Added by the generator")
+	body := f.Decls[0].(*dst.FuncDecl).Body
+	body.List = append(body.List, call)
+
+	file, fset, err := FromDst(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Errorf("Converted file printed as\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
+
+func TestFromDstImportManagement(t *testing.T) {
+	f, err := decorator.Parse("package p\n\nfunc F() {\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Identifiers with a path are only restored with import management
+	call := &dst.ExprStmt{X: &dst.CallExpr{Fun: &dst.Ident{Name: "Println", Path: "fmt"}}}
+	body := f.Decls[0].(*dst.FuncDecl).Body
+	body.List = append(body.List, call)
+
+	if _, _, err := FromDst(f); err == nil {
+		t.Error("FromDst accepted a file with import management")
+	}
+}
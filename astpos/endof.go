@@ -0,0 +1,24 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// Returns the end position the node gets when it is laid out with the
+// options, starting at the base of a new FileSet like RewriteNode.
+// EndOf(n)-1 is the number of bytes the node spans in the units of
+// the positions astpos assigns, e.g. for node handlers that measure
+// nodes without positioning them. The width heuristics of astpos
+// itself (see WithMaxLineWidth and WithCompactBodies) predict the
+// output of go/printer and measure that instead. The node itself is
+// not modified. Returns token.NoPos for a nil node.
+func EndOf(n ast.Node, opts ...Option) token.Pos {
+	if isNil(n) {
+		return token.NoPos
+	}
+	clone := Clone(n)
+	p := newPositioner(clone, token.NewFileSet(), newOptions(opts))
+	p.positionTokens()
+	return clone.End()
+}
@@ -0,0 +1,45 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestEndOf(t *testing.T) {
+	expr, err := parser.ParseExpr("a + b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ClearPositions(expr)
+	if end := EndOf(expr); end != 4 {
+		t.Errorf("a + b ends at %d without spaces, expected 4", end)
+	}
+	if end := EndOf(expr, WithColumns()); end != 6 {
+		t.Errorf("a + b ends at %d with spaces, expected 6", end)
+	}
+	if expr.Pos() != token.NoPos {
+		t.Errorf("EndOf positioned the node at %d", expr.Pos())
+	}
+
+	// The end matches the one of the rewritten node
+	f := parseSource(t, `package astpos
+
+	func f(xs []int) (sum int) {
+		for _, x := range xs {
+			sum += x
+		}
+		return sum
+	}
+	`)
+	ClearPositions(f)
+	decl := f.Decls[0].(*ast.FuncDecl)
+	for _, opts := range [][]Option{nil, {WithColumns()}} {
+		end := EndOf(decl, opts...)
+		node, _ := RewriteNode(Clone(decl), opts...)
+		if end != node.End() {
+			t.Errorf("EndOf returned %d, the rewritten declaration ends at %d", end, node.End())
+		}
+	}
+}
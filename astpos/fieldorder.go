@@ -0,0 +1,102 @@
+package astpos
+
+import (
+	"go/ast"
+	"slices"
+)
+
+// Compares two struct fields like cmp.Compare. Fields that compare
+// equal belong to the same group (see WithFieldOrder).
+type FieldOrder func(a, b *ast.Field) int
+
+// Orders embedded fields before named fields
+func EmbeddedFirst(a, b *ast.Field) int {
+	return compareBool(len(a.Names) == 0, len(b.Names) == 0)
+}
+
+// Orders exported fields before unexported fields.
+// A field with several names is exported if its first name is.
+func ExportedFirst(a, b *ast.Field) int {
+	return compareBool(isExportedField(a), isExportedField(b))
+}
+
+// Reorders the fields of the struct types declared with the given
+// names while rewriting. The fields are sorted stably by the order and
+// each group of fields that compare equal is separated from the next
+// one by a blank line. Orders can be combined with ThenBy.
+// Reordering changes the memory layout of the type and the meaning of
+// unkeyed composite literals of it (e.g. T{1, "x"}), so only the named
+// types are reordered and not their nested struct types or any other.
+func WithFieldOrder(order FieldOrder, typeNames ...string) Option {
+	return func(o *Options) {
+		o.FieldOrder = order
+		o.FieldOrderTypes = typeNames
+	}
+}
+
+// Returns an order that compares by o first and by next if o
+// considers the fields equal
+func (o FieldOrder) ThenBy(next FieldOrder) FieldOrder {
+	return func(a, b *ast.Field) int {
+		if c := o(a, b); c != 0 {
+			return c
+		}
+		return next(a, b)
+	}
+}
+
+// Marks the fields of the declared type to be reordered
+// if it is a struct type named in the options
+func (p *astPositioner) orderFieldsOf(n *ast.TypeSpec) {
+	p.orderedFields = nil
+	if st, ok := n.Type.(*ast.StructType); ok && p.opts.FieldOrder != nil && slices.Contains(p.opts.FieldOrderTypes, n.Name.Name) {
+		p.orderedFields = st.Fields
+	}
+}
+
+// Sorts the fields of the struct by the field order and returns
+// the separator that ends each field line and the field groups
+func (p *astPositioner) groupFields(fields *ast.FieldList) func() {
+	order := p.opts.FieldOrder
+	slices.SortStableFunc(fields.List, order)
+	return func() {
+		p.endLine()
+		i := p.index()
+		if i < len(fields.List)-1 && order(fields.List[i], fields.List[i+1]) != 0 {
+			p.blankLine()
+		}
+	}
+}
+
+// Orders true before false
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return -1
+	}
+	return 1
+}
+
+func isExportedField(f *ast.Field) bool {
+	if len(f.Names) == 0 {
+		// Embedded field, named after its type
+		typ := f.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		switch t := typ.(type) {
+		case *ast.Ident:
+			return t.IsExported()
+		case *ast.SelectorExpr:
+			return t.Sel.IsExported()
+		case *ast.IndexExpr:
+			return isExportedField(&ast.Field{Type: t.X})
+		case *ast.IndexListExpr:
+			return isExportedField(&ast.Field{Type: t.X})
+		}
+		return false
+	}
+	return f.Names[0].IsExported()
+}
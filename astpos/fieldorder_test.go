@@ -0,0 +1,95 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestFieldOrder(t *testing.T) {
+	src := `package astpos
+
+	type Config struct {
+		timeout int
+		Name string
+		Base
+		Port, port int
+		*mutex
+		Inner struct {
+			b int
+			A int
+		}
+	}
+
+	type Other struct {
+		b int
+		A int
+	}
+
+	func f(p struct {
+		b int
+		A int
+	}) {
+	}
+	`
+	expected := `package astpos
+
+type Config struct {
+	Base
+
+	*mutex
+
+	Name       string
+	Port, port int
+	Inner      struct {
+		b int
+		A int
+	}
+
+	timeout int
+}
+
+type Other struct {
+	b int
+	A int
+}
+
+func f(p struct {
+	b int
+	A int
+}) {
+}
+`
+	// Only the named type is reordered, not its nested struct
+	// type, other types or anonymous struct types
+	order := FieldOrder(EmbeddedFirst).ThenBy(ExportedFirst)
+	f, fset := RewritePositions(parseSource(t, src), WithFieldOrder(order, "Config"))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestFieldOrderComparator(t *testing.T) {
+	src := `package astpos
+
+	type T struct {
+		c int
+		a string
+		b int
+	}
+	`
+	expected := `package astpos
+
+type T struct {
+	c int
+	b int
+
+	a string
+}
+`
+	// Groups the fields by the name of their type
+	byType := func(a, b *ast.Field) int {
+		return strings.Compare(types.ExprString(a.Type), types.ExprString(b.Type))
+	}
+	f, fset := RewritePositions(parseSource(t, src), WithFieldOrder(byType, "T"))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
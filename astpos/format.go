@@ -0,0 +1,56 @@
+package astpos
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"io"
+
+	"golang.org/x/tools/imports"
+)
+
+// Rewrites the positions of the file and formats it with go/format.
+// Returns the formatted source code.
+func Format(f *ast.File, opts ...Option) ([]byte, error) {
+	options := newOptions(opts)
+
+	var buf bytes.Buffer
+	if err := fprint(&buf, f, options); err != nil {
+		return nil, err
+	}
+	if !options.ProcessImports {
+		return buf.Bytes(), nil
+	}
+	return imports.Process("", buf.Bytes(), nil)
+}
+
+// Same as Format but writes the source code to w.
+// The code is streamed to w unless goimports has to be run on it
+// (see WithImports). As with go/printer, parts of the code may have
+// been written when an error is returned.
+func Fprint(w io.Writer, f *ast.File, opts ...Option) error {
+	options := newOptions(opts)
+	if !options.ProcessImports {
+		return fprint(w, f, options)
+	}
+
+	src, err := Format(f, opts...)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func fprint(w io.Writer, f *ast.File, opts Options) error {
+	f, fset := RewritePositionsWithOptions(f, opts)
+	return format.Node(w, fset, f)
+}
+
+// Makes Format and Fprint run goimports on the formatted code, which adds
+// missing imports, removes unused ones and sorts them into groups
+func WithImports() Option {
+	return func(o *Options) {
+		o.ProcessImports = true
+	}
+}
@@ -0,0 +1,83 @@
+package astpos
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	src := `package astpos
+
+	import "fmt"
+
+	func f() { fmt.Println("formatted") }
+	`
+
+	expected := `package astpos
+
+import "fmt"
+
+func f() {
+	fmt.Println("formatted")
+}
+`
+
+	out, err := Format(parseSource(t, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, string(out), expected)
+}
+
+func TestFormatWithImports(t *testing.T) {
+	src := `package astpos
+
+	import "os"
+
+	func f() { fmt.Println(strings.ToUpper("formatted")) }
+	`
+
+	expected := `package astpos
+
+import (
+	"fmt"
+	"strings"
+)
+
+func f() {
+	fmt.Println(strings.ToUpper("formatted"))
+}
+`
+
+	out, err := Format(parseSource(t, src), WithImports())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, string(out), expected)
+}
+
+func TestFprint(t *testing.T) {
+	src := `package astpos
+
+	func f() { g() }
+	`
+
+	expected := `package astpos
+
+func f() {
+	g()
+}
+`
+
+	var buf strings.Builder
+	if err := Fprint(&buf, parseSource(t, src)); err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, buf.String(), expected)
+
+	buf.Reset()
+	if err := Fprint(&buf, parseSource(t, src), WithImports()); err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, buf.String(), expected)
+}
@@ -0,0 +1,72 @@
+package astpos
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+// Parses the source, strips all positions, rewrites them and checks
+// that the formatted output parses into the same tree as the source
+func FuzzRewritePositions(f *testing.F) {
+	f.Add(sampleSource)
+	f.Add(`package p
+
+	type T[K comparable, V any] struct{ m map[K]V; c chan<- V }
+
+	func (t *T[K, V]) Get(k K) (v V, ok bool) {
+		defer func() { recover() }()
+		select { case t.c <- v: default: }
+		switch x := any(k).(type) { case int: _ = x }
+	loop:
+		for i := range 10 { if i > 5 { break loop } else { continue } }
+		v, ok = t.m[k]
+		return
+	}
+	`)
+	f.Add(`package p
+
+	var s = []int{1, 2, 3}[1:2:3]
+	var f = func(xs ...int) int { return len(xs) }(s...)
+	`)
+
+	f.Fuzz(func(t *testing.T, src string) {
+		// The tree of the gofmt output is compared, since go/printer
+		// normalizes some trees, e.g. by adding parentheses
+		gofmt, err := format.Source([]byte(src))
+		if err != nil {
+			return
+		}
+		mode := parser.SkipObjectResolution
+		original, err := parser.ParseFile(token.NewFileSet(), "x.go", gofmt, mode)
+		if err != nil {
+			return
+		}
+		ClearPositions(original)
+		want := treeString(original)
+
+		file, fset := RewritePositions(original, WithInvariantChecks())
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, file); err != nil {
+			t.Fatalf("format: %v", err)
+		}
+		reparsed, err := parser.ParseFile(token.NewFileSet(), "x.go", buf.Bytes(), mode)
+		if err != nil {
+			t.Fatalf("output does not parse: %v\n%s", err, buf.String())
+		}
+		ClearPositions(reparsed)
+		if got := treeString(reparsed); got != want {
+			t.Fatalf("output parses into a different tree:\n%s", buf.String())
+		}
+	})
+}
+
+// Returns a dump of the tree without nil fields
+func treeString(f *ast.File) string {
+	var buf bytes.Buffer
+	ast.Fprint(&buf, nil, f, ast.NotNilFilter)
+	return buf.String()
+}
@@ -0,0 +1,88 @@
+package gen
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// Returns the identifier, e.g. a variable or type name
+func Ident(name string) *ast.Ident {
+	return ast.NewIdent(name)
+}
+
+// Returns the selector chain of the names, e.g. Sel("fmt", "Println")
+// for fmt.Println. A single name is returned as an identifier.
+func Sel(x string, names ...string) ast.Expr {
+	var expr ast.Expr = ast.NewIdent(x)
+	for _, name := range names {
+		expr = Dot(expr, name)
+	}
+	return expr
+}
+
+// Returns the selection of the field or method of the expression
+func Dot(x ast.Expr, name string) *ast.SelectorExpr {
+	return &ast.SelectorExpr{X: x, Sel: ast.NewIdent(name)}
+}
+
+// Returns the call of the function with the arguments
+func Call(fun ast.Expr, args ...ast.Expr) *ast.CallExpr {
+	return &ast.CallExpr{Fun: fun, Args: args}
+}
+
+// Returns the string as a quoted string literal
+func Str(s string) *ast.BasicLit {
+	return &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(s)}
+}
+
+// Returns the integer literal
+func Int(i int) *ast.BasicLit {
+	return &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(i)}
+}
+
+// Returns true or false
+func Bool(b bool) *ast.Ident {
+	return ast.NewIdent(strconv.FormatBool(b))
+}
+
+// Returns nil
+func Nil() *ast.Ident {
+	return ast.NewIdent("nil")
+}
+
+// Returns the binary expression x op y
+func Binary(x ast.Expr, op token.Token, y ast.Expr) *ast.BinaryExpr {
+	return &ast.BinaryExpr{X: x, Op: op, Y: y}
+}
+
+// Returns the unary expression op x
+func Unary(op token.Token, x ast.Expr) *ast.UnaryExpr {
+	return &ast.UnaryExpr{Op: op, X: x}
+}
+
+// Returns &x
+func Addr(x ast.Expr) *ast.UnaryExpr {
+	return Unary(token.AND, x)
+}
+
+// Returns *x, a dereference or a pointer type
+func Star(x ast.Expr) *ast.StarExpr {
+	return &ast.StarExpr{X: x}
+}
+
+// Returns x[index]
+func Index(x, index ast.Expr) *ast.IndexExpr {
+	return &ast.IndexExpr{X: x, Index: index}
+}
+
+// Returns the composite literal of the type with the elements,
+// e.g. key/value pairs built by KeyValue
+func Composite(typ ast.Expr, elts ...ast.Expr) *ast.CompositeLit {
+	return &ast.CompositeLit{Type: typ, Elts: elts}
+}
+
+// Returns the key/value pair of a composite literal
+func KeyValue(key, value ast.Expr) *ast.KeyValueExpr {
+	return &ast.KeyValueExpr{Key: key, Value: value}
+}
@@ -0,0 +1,37 @@
+package gen
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"testing"
+
+	"github.com/snonky/astpos/astpos"
+)
+
+func TestExprs(t *testing.T) {
+	tests := []struct {
+		expr     ast.Expr
+		expected string
+	}{
+		{Call(Sel("fmt", "Println"), Str("hi")), `fmt.Println("hi")`},
+		{Sel("a", "b", "c"), "a.b.c"},
+		{Sel("x"), "x"},
+		{Call(Dot(Call(Ident("f")), "Close")), "f().Close()"},
+		{Binary(Ident("err"), token.NEQ, Nil()), "err != nil"},
+		{Binary(Int(1), token.ADD, Unary(token.SUB, Int(2))), "1 + -2"},
+		{Addr(Composite(Ident("T"), KeyValue(Ident("A"), Bool(true)))), "&T{A: true}"},
+		{Index(Star(Ident("p")), Str("k")), `(*p)["k"]`},
+	}
+	for _, test := range tests {
+		node, fset := astpos.RewriteNode(test.expr)
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, node); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("Expression printed as %s, expected %s", buf.String(), test.expected)
+		}
+	}
+}
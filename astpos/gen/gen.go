@@ -0,0 +1,152 @@
+// Package gen builds position-free declarations, expressions and
+// statements for code generators. The nodes are meant to be positioned
+// by astpos.RewritePositions or printed with astpos.Format.
+package gen
+
+import (
+	"go/ast"
+	"go/token"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/snonky/astpos/astpos"
+)
+
+// Field of a struct built by Struct. The builders clone the type,
+// so that the same Field can be passed to several of them.
+type Field struct {
+	Name string
+	Type ast.Expr
+
+	// Optional tag, e.g. built by astpos.StructTag
+	Tag *ast.BasicLit
+
+	// Optional doc comment
+	Doc *ast.CommentGroup
+}
+
+// Returns the declaration of a struct type with the fields
+func Struct(name string, fields ...Field) *ast.GenDecl {
+	list := make([]*ast.Field, len(fields))
+	for i, f := range fields {
+		list[i] = &ast.Field{
+			Doc:   f.Doc,
+			Names: []*ast.Ident{ast.NewIdent(f.Name)},
+			Type:  astpos.Clone(f.Type),
+			Tag:   f.Tag,
+		}
+	}
+	return &ast.GenDecl{
+		Tok: token.TYPE,
+		Specs: []ast.Spec{&ast.TypeSpec{
+			Name: ast.NewIdent(name),
+			Type: &ast.StructType{Fields: &ast.FieldList{List: list}},
+		}},
+	}
+}
+
+// Returns a method with a pointer receiver on the type. The receiver
+// is named after the first letter of the type (see Receiver).
+func Method(typeName, name string, params, results []*ast.Field, body ...ast.Stmt) *ast.FuncDecl {
+	return &ast.FuncDecl{
+		Recv: &ast.FieldList{List: []*ast.Field{{
+			Names: []*ast.Ident{ast.NewIdent(Receiver(typeName))},
+			Type:  &ast.StarExpr{X: ast.NewIdent(typeName)},
+		}}},
+		Name: ast.NewIdent(name),
+		Type: &ast.FuncType{
+			Params:  &ast.FieldList{List: params},
+			Results: fieldList(results),
+		},
+		Body: &ast.BlockStmt{List: body},
+	}
+}
+
+// Returns a method that returns the field of the type. The method is
+// named after the field, e.g. Name for the field name, or GetName if
+// the field is exported.
+func Getter(typeName string, f Field) *ast.FuncDecl {
+	name := exported(f.Name)
+	if name == f.Name {
+		name = "Get" + name
+	}
+	return Method(typeName, name, nil, []*ast.Field{{Type: astpos.Clone(f.Type)}},
+		&ast.ReturnStmt{Results: []ast.Expr{fieldOf(typeName, f)}},
+	)
+}
+
+// Returns a method that sets the field of the type, e.g. SetName
+// for the field name
+func Setter(typeName string, f Field) *ast.FuncDecl {
+	param := paramName(f.Name, Receiver(typeName))
+	return Method(typeName, "Set"+exported(f.Name), []*ast.Field{Param(param, astpos.Clone(f.Type))}, nil,
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{fieldOf(typeName, f)},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{ast.NewIdent(param)},
+		},
+	)
+}
+
+// Returns a function NewT that takes a parameter for each of the fields
+// and returns a pointer to a T with the fields set
+func Constructor(typeName string, fields ...Field) *ast.FuncDecl {
+	params := make([]*ast.Field, len(fields))
+	elts := make([]ast.Expr, len(fields))
+	for i, f := range fields {
+		param := paramName(f.Name, "")
+		params[i] = Param(param, astpos.Clone(f.Type))
+		elts[i] = &ast.KeyValueExpr{Key: ast.NewIdent(f.Name), Value: ast.NewIdent(param)}
+	}
+	return &ast.FuncDecl{
+		Name: ast.NewIdent("New" + exported(typeName)),
+		Type: &ast.FuncType{
+			Params:  &ast.FieldList{List: params},
+			Results: fieldList([]*ast.Field{{Type: &ast.StarExpr{X: ast.NewIdent(typeName)}}}),
+		},
+		Body: &ast.BlockStmt{List: []ast.Stmt{&ast.ReturnStmt{Results: []ast.Expr{
+			&ast.UnaryExpr{Op: token.AND, X: &ast.CompositeLit{Type: ast.NewIdent(typeName), Elts: elts}},
+		}}}},
+	}
+}
+
+// Returns a parameter or result with the name and type
+func Param(name string, typ ast.Expr) *ast.Field {
+	return &ast.Field{Names: []*ast.Ident{ast.NewIdent(name)}, Type: typ}
+}
+
+// Returns the name of the receiver of the methods of the type,
+// the lower case first letter of the type name
+func Receiver(typeName string) string {
+	r, _ := utf8.DecodeRuneInString(typeName)
+	return string(unicode.ToLower(r))
+}
+
+// Returns the field list of the results, nil if there are none
+func fieldList(fields []*ast.Field) *ast.FieldList {
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ast.FieldList{List: fields}
+}
+
+// Returns the selector of the field on the receiver of the type
+func fieldOf(typeName string, f Field) *ast.SelectorExpr {
+	return &ast.SelectorExpr{X: ast.NewIdent(Receiver(typeName)), Sel: ast.NewIdent(f.Name)}
+}
+
+func exported(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
+// Returns the field name with a lower case first letter. Names that
+// are keywords or taken by the receiver get a trailing underscore.
+func paramName(field, receiver string) string {
+	r, size := utf8.DecodeRuneInString(field)
+	name := string(unicode.ToLower(r)) + field[size:]
+	if token.IsKeyword(name) || name == receiver {
+		name += "_"
+	}
+	return name
+}
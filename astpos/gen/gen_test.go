@@ -0,0 +1,74 @@
+package gen
+
+import (
+	"go/ast"
+	"go/token"
+	"testing"
+
+	"github.com/snonky/astpos/astpos"
+)
+
+func TestDecls(t *testing.T) {
+	fields := []Field{
+		{Name: "name", Type: ast.NewIdent("string"), Tag: astpos.StructTag(map[string]string{"json": "name"})},
+		{Name: "Type", Type: &ast.ArrayType{Elt: ast.NewIdent("byte")}},
+		{Name: "u", Type: ast.NewIdent("int"), Doc: astpos.DocComment("Shares the name of the receiver")},
+	}
+	f := &ast.File{
+		Name: ast.NewIdent("gen"),
+		Decls: []ast.Decl{
+			Struct("User", fields...),
+			Constructor("User", fields...),
+			Getter("User", fields[0]),
+			Getter("User", fields[1]),
+			Setter("User", fields[0]),
+			Setter("User", fields[2]),
+		},
+	}
+	expected := `package gen
+
+type User struct {
+	name string ` + "`json:\"name\"`" + `
+	Type []byte
+	// Shares the name of the receiver
+	u int
+}
+
+func NewUser(name string, type_ []byte, u int) *User {
+	return &User{
+		name: name,
+		Type: type_,
+		u:    u,
+	}
+}
+
+func (u *User) Name() string {
+	return u.name
+}
+
+func (u *User) GetType() []byte {
+	return u.Type
+}
+
+func (u *User) SetName(name string) {
+	u.name = name
+}
+
+func (u *User) SetU(u_ int) {
+	u.u = u_
+}
+`
+	src, err := astpos.Format(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != expected {
+		t.Fatalf("Generated code differs from the expected code:\n%s", src)
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n != nil && n.Pos() == token.NoPos {
+			t.Errorf("%T has no position", n)
+		}
+		return n != nil
+	})
+}
@@ -0,0 +1,95 @@
+package gen
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// Case of a type switch built by TypeSwitch.
+// A case without types is the default case.
+type TypeCase struct {
+	Types []ast.Expr
+	Body  []ast.Stmt
+}
+
+// Returns the check of err that returns the results followed by err:
+//
+//	if err != nil {
+//		return results..., err
+//	}
+func IfErrReturn(results ...ast.Expr) []ast.Stmt {
+	return []ast.Stmt{&ast.IfStmt{
+		Cond: Binary(Ident("err"), token.NEQ, Nil()),
+		Body: errReturn(results),
+	}}
+}
+
+// Returns the call, assigning its results to the names and err,
+// followed by the check of err (see IfErrReturn). Without names
+// the call is the init statement of the check:
+//
+//	if err := call; err != nil {
+//		return results..., err
+//	}
+func CallErr(names []string, call ast.Expr, results ...ast.Expr) []ast.Stmt {
+	lhs := make([]ast.Expr, 0, len(names)+1)
+	for _, name := range names {
+		lhs = append(lhs, Ident(name))
+	}
+	assign := &ast.AssignStmt{
+		Lhs: append(lhs, Ident("err")),
+		Tok: token.DEFINE,
+		Rhs: []ast.Expr{call},
+	}
+	check := IfErrReturn(results...)
+	if len(names) == 0 {
+		check[0].(*ast.IfStmt).Init = assign
+		return check
+	}
+	return append([]ast.Stmt{assign}, check...)
+}
+
+// Returns the loop over x. An empty key is replaced by _ if there is
+// a value, without both the loop is a for range x.
+func ForRange(key, value string, x ast.Expr, body ...ast.Stmt) []ast.Stmt {
+	loop := &ast.RangeStmt{X: x, Body: &ast.BlockStmt{List: body}}
+	if key != "" || value != "" {
+		if key == "" {
+			key = "_"
+		}
+		loop.Key = Ident(key)
+		loop.Tok = token.DEFINE
+	}
+	if value != "" {
+		loop.Value = Ident(value)
+	}
+	return []ast.Stmt{loop}
+}
+
+// Returns defer name.Close()
+func DeferClose(name string) []ast.Stmt {
+	return []ast.Stmt{&ast.DeferStmt{Call: Call(Sel(name, "Close"))}}
+}
+
+// Returns the type switch over x, which binds the value of each
+// case to name unless it is empty
+func TypeSwitch(name string, x ast.Expr, cases ...TypeCase) []ast.Stmt {
+	var assign ast.Stmt = &ast.ExprStmt{X: &ast.TypeAssertExpr{X: x}}
+	if name != "" {
+		assign = &ast.AssignStmt{
+			Lhs: []ast.Expr{Ident(name)},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.TypeAssertExpr{X: x}},
+		}
+	}
+	clauses := make([]ast.Stmt, len(cases))
+	for i, c := range cases {
+		clauses[i] = &ast.CaseClause{List: c.Types, Body: c.Body}
+	}
+	return []ast.Stmt{&ast.TypeSwitchStmt{Assign: assign, Body: &ast.BlockStmt{List: clauses}}}
+}
+
+func errReturn(results []ast.Expr) *ast.BlockStmt {
+	results = append(results[:len(results):len(results)], Ident("err"))
+	return &ast.BlockStmt{List: []ast.Stmt{&ast.ReturnStmt{Results: results}}}
+}
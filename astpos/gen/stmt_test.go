@@ -0,0 +1,70 @@
+package gen
+
+import (
+	"go/ast"
+	"slices"
+	"testing"
+
+	"github.com/snonky/astpos/astpos"
+)
+
+func TestStmts(t *testing.T) {
+	body := slices.Concat(
+		CallErr([]string{"f"}, Call(Sel("os", "Open"), Ident("name")), Nil()),
+		DeferClose("f"),
+		CallErr(nil, Call(Sel("f", "Sync")), Nil()),
+		ForRange("", "v", Ident("values"),
+			TypeSwitch("x", Ident("v"),
+				TypeCase{Types: []ast.Expr{Ident("int"), Ident("uint")}, Body: []ast.Stmt{
+					&ast.ExprStmt{X: Call(Ident("println"), Ident("x"))},
+				}},
+				TypeCase{},
+			)...,
+		),
+		ForRange("", "", Ident("values")),
+		IfErrReturn(Nil()),
+	)
+	f := &ast.File{
+		Name: Ident("gen"),
+		Decls: []ast.Decl{&ast.FuncDecl{
+			Name: Ident("f"),
+			Type: &ast.FuncType{
+				Params:  &ast.FieldList{List: []*ast.Field{Param("name", Ident("string")), Param("values", &ast.ArrayType{Elt: Ident("any")})}},
+				Results: &ast.FieldList{List: []*ast.Field{{Type: Star(Sel("os", "File"))}, {Type: Ident("error")}}},
+			},
+			Body: &ast.BlockStmt{List: body},
+		}},
+	}
+	expected := `package gen
+
+func f(name string, values []any) (*os.File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		switch x := v.(type) {
+		case int, uint:
+			println(x)
+		default:
+		}
+	}
+	for range values {
+	}
+	if err != nil {
+		return nil, err
+	}
+}
+`
+	src, err := astpos.Format(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != expected {
+		t.Fatalf("Generated code differs from the expected code:\n%s", src)
+	}
+}
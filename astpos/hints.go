@@ -0,0 +1,78 @@
+package astpos
+
+import "go/ast"
+
+// Formatting hints for single nodes, combined with |
+type Hint uint
+
+const (
+	// Starts a new line before the node
+	HintNewlineBefore Hint = 1 << iota
+	// Separates the node from the following one by an empty line
+	HintBlankLineAfter
+	// Keeps the node on one line, e.g. a composite literal that
+	// would be split by its number of elements or a long call
+	HintOneLine
+	// Spreads a composite literal, call, function signature, return
+	// statement or binary expression chain over multiple lines with
+	// one element per line
+	HintExpand
+	// Separates the node from the previous one of its list by an
+	// empty line, e.g. to start a paragraph of statements
+	HintBlankLineBefore
+)
+
+// Formatting hints keyed by the nodes they apply to
+// (see WithHints)
+type Hints map[ast.Node]Hint
+
+// Sets per node formatting hints that take precedence
+// over the default line breaks
+func WithHints(hints Hints) Option {
+	return func(o *Options) {
+		o.Hints = hints
+	}
+}
+
+// Starts a paragraph at the statement by separating it from the
+// previous statement with an empty line
+func (h Hints) BlankLineBefore(stmt ast.Stmt) {
+	h[stmt] |= HintBlankLineBefore
+}
+
+// Returns true if the node has the hint
+func (p *astPositioner) hasHint(n ast.Node, hint Hint) bool {
+	return p.opts.Hints[n]&hint != 0
+}
+
+// Traverses the node within the line breaks requested by its hints.
+// Returns false if the node has no hints to apply.
+func (p *astPositioner) applyHints(n ast.Node) bool {
+	hint, ok := p.opts.Hints[n]
+	if !ok || p.hinted[n] {
+		return false
+	}
+	if p.hinted == nil {
+		p.hinted = make(map[ast.Node]bool)
+	}
+	p.hinted[n] = true
+
+	if hint&HintBlankLineBefore != 0 && p.index() > 0 {
+		p.blankLine()
+	}
+	if hint&HintNewlineBefore != 0 {
+		p.endLine()
+	}
+	oneLine := p.oneLine
+	if hint&HintOneLine != 0 {
+		p.oneLine = true
+	}
+	p.traverse(n)
+	p.oneLine = oneLine
+	if hint&HintBlankLineAfter != 0 {
+		p.blankLine()
+	}
+
+	delete(p.hinted, n)
+	return true
+}
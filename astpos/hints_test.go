@@ -0,0 +1,74 @@
+package astpos
+
+import (
+	"go/ast"
+	"testing"
+)
+
+func TestHints(t *testing.T) {
+	src := `package astpos
+
+	func f() {
+		opts := Options{Filename: "a.go"}
+		values := []int{1, 2, 3, 4, 5}
+		register("a-rather-long-name", handleTheRequest, withRetries(3), withTimeout(30))
+		run(opts, values)
+		done()
+	}
+	`
+
+	expected := `package astpos
+
+func f() {
+	opts := Options{
+		Filename: "a.go",
+	}
+	values := []int{1, 2, 3, 4, 5}
+	register("a-rather-long-name", handleTheRequest, withRetries(3), withTimeout(30))
+	run(opts, values)
+
+	done()
+}
+`
+
+	f := parseSource(t, src)
+	stmts := f.Decls[0].(*ast.FuncDecl).Body.List
+	hints := Hints{
+		stmts[0].(*ast.AssignStmt).Rhs[0]: HintExpand,
+		stmts[1].(*ast.AssignStmt).Rhs[0]: HintOneLine,
+		stmts[2].(*ast.ExprStmt).X:        HintOneLine,
+		stmts[3]:                          HintBlankLineAfter,
+	}
+
+	f, fset := RewritePositions(f, WithHints(hints), WithMaxLineWidth(60))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestBlankLineBefore(t *testing.T) {
+	src := `package astpos
+
+	func f() {
+		a := 1
+		b := 2
+		println(a, b)
+	}
+	`
+
+	expected := `package astpos
+
+func f() {
+	a := 1
+	b := 2
+
+	println(a, b)
+}
+`
+
+	f := parseSource(t, src)
+	stmts := f.Decls[0].(*ast.FuncDecl).Body.List
+	hints := Hints{}
+	hints.BlankLineBefore(stmts[0])
+	hints.BlankLineBefore(stmts[2])
+	f, fset := RewritePositions(f, WithHints(hints))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
@@ -0,0 +1,135 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Separates the imports of parenthesized import declarations into
+// groups like goimports -local does: standard library imports,
+// external imports and local imports, whose paths start with one
+// of the given prefixes. The groups are separated by empty lines,
+// go/format sorts the imports within each group.
+func WithImportGroups(localPrefixes ...string) Option {
+	return func(o *Options) {
+		o.GroupImports = true
+		o.LocalImportPrefixes = localPrefixes
+	}
+}
+
+// Splits the import specs into a group of standard library imports,
+// a group of external imports and a group of local imports.
+// Empty groups are omitted.
+func importGroups(specs []ast.Spec, localPrefixes []string) [][]ast.Spec {
+	var std, other, local []ast.Spec
+	for _, spec := range specs {
+		path, err := strconv.Unquote(spec.(*ast.ImportSpec).Path.Value)
+		switch {
+		case err != nil:
+			other = append(other, spec)
+		case isLocalImport(path, localPrefixes):
+			local = append(local, spec)
+		case isStdImport(path):
+			std = append(std, spec)
+		default:
+			other = append(other, spec)
+		}
+	}
+	var groups [][]ast.Spec
+	for _, group := range [][]ast.Spec{std, other, local} {
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// Standard library import paths have no dot in their first element
+func isStdImport(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// Same matching as goimports -local
+func isLocalImport(path string, localPrefixes []string) bool {
+	for _, prefix := range localPrefixes {
+		if strings.HasPrefix(path, prefix) || strings.TrimSuffix(prefix, "/") == path {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds imports of the given paths that the file does not import yet.
+// They are merged into the first import declaration, which becomes
+// parenthesized if necessary, or into a new import declaration after
+// the existing ones. Like astutil.AddImport, declarations that import
+// "C" are left alone, their doc comment is the cgo preamble.
+// The positions of the new nodes are set by the next rewrite of the file.
+func EnsureImports(f *ast.File, paths ...string) {
+	var decl *ast.GenDecl
+	// Index of the new declaration
+	index := 0
+	for i, d := range f.Decls {
+		d, ok := d.(*ast.GenDecl)
+		if !ok || d.Tok != token.IMPORT {
+			continue
+		}
+		index = i + 1
+		if !importsC(d) {
+			decl = d
+			break
+		}
+	}
+
+	for _, path := range paths {
+		if hasImport(f, path) {
+			continue
+		}
+		if decl == nil {
+			decl = &ast.GenDecl{Tok: token.IMPORT}
+			f.Decls = slices.Insert(f.Decls, index, ast.Decl(decl))
+		}
+		spec := &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(path)}}
+		decl.Specs = append(decl.Specs, spec)
+		f.Imports = append(f.Imports, spec)
+	}
+
+	if decl != nil && len(decl.Specs) > 1 && !decl.Lparen.IsValid() {
+		// Any valid position marks the declaration as parenthesized
+		decl.Lparen = decl.TokPos + 1
+		decl.Rparen = decl.Lparen
+	}
+}
+
+// Returns true if the file imports the path under a usable name,
+// i.e. not only as a blank import
+func hasImport(f *ast.File, path string) bool {
+	for _, d := range f.Decls {
+		d, ok := d.(*ast.GenDecl)
+		if !ok || d.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range d.Specs {
+			spec := spec.(*ast.ImportSpec)
+			p, err := strconv.Unquote(spec.Path.Value)
+			if err == nil && p == path && (spec.Name == nil || spec.Name.Name != "_") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns true if the import declaration imports "C"
+func importsC(d *ast.GenDecl) bool {
+	for _, spec := range d.Specs {
+		if spec.(*ast.ImportSpec).Path.Value == `"C"` {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package astpos
+
+import (
+	"bytes"
+	"go/format"
+	"strings"
+	"testing"
+)
+
+func TestImportGroups(t *testing.T) {
+	src := `package astpos
+
+	import (
+		"example.com/project/internal/a"
+		"github.com/other/b"
+		"fmt"
+		"example.com/project/c"
+		"os"
+		"golang.org/x/tools/imports"
+	)
+	`
+
+	expected := `package astpos
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/other/b"
+	"golang.org/x/tools/imports"
+
+	"example.com/project/c"
+	"example.com/project/internal/a"
+)
+`
+
+	f, fset := RewritePositions(parseSource(t, src), WithImportGroups("example.com/project"))
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, buf.String(), expected)
+}
+
+func TestIsLocalImport(t *testing.T) {
+	prefixes := []string{"example.com/project/", "example.org/x"}
+	for path, local := range map[string]bool{
+		"example.com/project":      true,
+		"example.com/project/a":    true,
+		"example.com/projectother": false,
+		"example.org/x":            true,
+		"example.org/xy":           true,
+		"fmt":                      false,
+	} {
+		if isLocalImport(path, prefixes) != local {
+			t.Errorf("isLocalImport(%q) != %v", path, local)
+		}
+	}
+}
+
+func TestEnsureImports(t *testing.T) {
+	tests := []struct {
+		src, expected string
+	}{{
+		src: `package astpos
+
+		var x = 1
+		`,
+		expected: `package astpos
+
+import (
+	"fmt"
+	"os"
+)
+
+var x = 1
+`,
+	}, {
+		src: `package astpos
+
+		import "fmt"
+		`,
+		expected: `package astpos
+
+import (
+	"fmt"
+	"os"
+)
+`,
+	}, {
+		src: `package astpos
+
+		import (
+			_ "os"
+			"strings"
+		)
+		`,
+		expected: `package astpos
+
+import (
+	"fmt"
+	"os"
+	_ "os"
+	"strings"
+)
+`,
+	}, {
+		// The cgo preamble stays the doc of import "C"
+		src: `package astpos
+
+		// #include <stdlib.h>
+		import "C"
+		`,
+		expected: `package astpos
+
+// #include <stdlib.h>
+import "C"
+import (
+	"fmt"
+	"os"
+)
+`,
+	}}
+
+	for _, test := range tests {
+		f := parseSource(t, test.src)
+		EnsureImports(f, "fmt", "os", "fmt")
+		if len(f.Imports) != strings.Count(test.expected, `"`)/2 {
+			t.Errorf("File has %d imports", len(f.Imports))
+		}
+		f, fset := RewritePositions(f)
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, f); err != nil {
+			t.Fatal(err)
+		}
+		checkResult(t, buf.String(), test.expected)
+	}
+}
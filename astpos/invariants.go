@@ -0,0 +1,65 @@
+package astpos
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// Makes the rewrite panic as soon as the position counter moves
+// backwards or a node ends before it starts or outside of the
+// positions that were assigned while it was traversed. Meant for
+// debugging the positioning of custom nodes (see WithNodeHandler)
+// and astpos itself, it slows the rewrite down.
+func WithInvariantChecks() Option {
+	return func(o *Options) {
+		o.CheckInvariants = true
+	}
+}
+
+// A node whose children are being traversed
+// together with the counter at its start
+type invariantFrame struct {
+	node  ast.Node
+	start int
+}
+
+// Same as down but checks the invariants of each node
+// after it was positioned
+func (p *astPositioner) checkedDown(n ast.Node) bool {
+	if n == nil {
+		// Inspect calls with nil after the children of a node
+		frame := p.invariantStack[len(p.invariantStack)-1]
+		p.invariantStack = p.invariantStack[:len(p.invariantStack)-1]
+		p.checkInvariants(frame.node, frame.start)
+		return false
+	}
+	start := p.p
+	if !p.down(n) {
+		if !isNil(n) {
+			p.checkInvariants(n, start)
+		}
+		return false
+	}
+	p.invariantStack = append(p.invariantStack, invariantFrame{n, start})
+	return true
+}
+
+func (p *astPositioner) checkInvariants(n ast.Node, start int) {
+	pos, end := n.Pos(), n.End()
+	if fn, ok := n.(*ast.FuncType); ok && fn.Params != nil {
+		// Function declarations position the keyword
+		// before the receiver and name
+		pos = fn.Params.Pos()
+	}
+	switch {
+	case p.p < start:
+		panic(fmt.Sprintf("astpos: position counter moved back from %d to %d in %T", start, p.p, n))
+	case !pos.IsValid() || !end.IsValid():
+		// Nodes that are not printed, e.g. an empty field list
+	case end < pos:
+		panic(fmt.Sprintf("astpos: %T ends at %d before its start at %d", n, end, pos))
+	case pos < token.Pos(start) || end > token.Pos(p.p):
+		panic(fmt.Sprintf("astpos: %T at %d-%d lies outside of its traversal at %d-%d", n, pos, end, start, p.p))
+	}
+}
@@ -0,0 +1,35 @@
+package astpos
+
+import (
+	"go/ast"
+	"strings"
+	"testing"
+)
+
+func TestInvariantChecks(t *testing.T) {
+	f, fset := RewritePositions(parseSource(t, largeSource()), WithInvariantChecks(), WithColumns())
+	if err := Verify(f, fset); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInvariantChecksPanic(t *testing.T) {
+	src := "package astpos\n\nvar x = y\n"
+	f := parseSource(t, src)
+
+	defer func() {
+		err := recover()
+		if msg, ok := err.(string); !ok || !strings.Contains(msg, "*ast.Ident") {
+			t.Errorf("Unexpected panic %v", err)
+		}
+	}()
+	// A handler that positions the identifier before the current position
+	RewritePositions(f, WithInvariantChecks(), WithNodeHandler(func(p *Positioner, n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == "y" {
+			id.NamePos = p.Pos() - 2
+			return true
+		}
+		return false
+	}))
+	t.Error("The misplaced identifier was not detected")
+}
@@ -0,0 +1,44 @@
+package astpos
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// Origin locations of declarations, e.g. in the templates that
+// produced them (see WithLineDirectives)
+type LineDirectives map[ast.Decl]token.Position
+
+// Puts a //line directive between the doc comment and the keyword
+// of each declaration in the map, so that compiler errors, stack
+// traces and coverage point to the origin location instead of the
+// generated file. The directive applies to all following lines up
+// to the next directive. Declarations without a filename or line
+// are skipped.
+func WithLineDirectives(directives LineDirectives) Option {
+	return func(o *Options) {
+		o.LineDirectives = directives
+	}
+}
+
+// Positions the //line directive of the declaration on its own line.
+// go/printer only keeps directives at the start of a line
+// unindented, so the indentation of Columns mode is left out.
+func (p *astPositioner) handleLineDirective(decl ast.Decl) {
+	pos, ok := p.opts.LineDirectives[decl]
+	if !ok || pos.Filename == "" || pos.Line <= 0 {
+		return
+	}
+	text := fmt.Sprintf("//line %s:%d", pos.Filename, pos.Line)
+	if pos.Column > 0 {
+		text += fmt.Sprintf(":%d", pos.Column)
+	}
+
+	p.endLine()
+	p.indentPending = false
+	c := &ast.Comment{Slash: p.pc(), Text: text}
+	p.comments = append(p.comments, &ast.CommentGroup{List: []*ast.Comment{c}})
+	p.moveText(text)
+	p.newline()
+}
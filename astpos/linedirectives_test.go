@@ -0,0 +1,55 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestLineDirectives(t *testing.T) {
+	src := `package main
+
+	// f does things
+	func f() {
+		const c = 1
+	}
+
+	var x = 1
+	`
+	expected := `package main
+
+// f does things
+//line tmpl.go:12
+func f() {
+//line tmpl.go:20:3
+	const c = 1
+}
+
+var x = 1
+`
+
+	f := parseSource(t, src)
+	fn := f.Decls[0].(*ast.FuncDecl)
+	local := fn.Body.List[0].(*ast.DeclStmt).Decl
+	directives := LineDirectives{
+		fn:         {Filename: "tmpl.go", Line: 12},
+		local:      {Filename: "tmpl.go", Line: 20, Column: 3},
+		f.Decls[1]: {Line: 30},
+	}
+	out, err := Format(f, WithLineDirectives(directives), WithColumns())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, string(out), expected)
+
+	fset := token.NewFileSet()
+	parsed, err := parser.ParseFile(fset, "x.go", out, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := fset.Position(parsed.Decls[0].Pos())
+	if pos.Filename != "tmpl.go" || pos.Line != 12 {
+		t.Errorf("Declaration is at %v, expected tmpl.go:12", pos)
+	}
+}
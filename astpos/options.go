@@ -0,0 +1,292 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// Configures the behaviour of RewritePositionsWithOptions.
+// The zero value selects the default behaviour of RewritePositions.
+type Options struct {
+	// Composite literals with at least this many elements are
+	// split over multiple lines.
+	// 0 selects the default of the profile (4 in ProfileV1),
+	// a negative value disables the split.
+	CompositeLitThreshold int
+
+	// Version of the layout heuristics (see WithProfile)
+	Profile Profile
+
+	// Selects the layout of each composite literal, overrides
+	// CompositeLitThreshold (see WithCompositeLitLayout)
+	CompositeLitLayout func(*ast.CompositeLit) LiteralLayout
+
+	// Called on every node before its position is set
+	// (see WithNodeHandler)
+	NodeHandler func(p *Positioner, n ast.Node) bool
+
+	// Formatting hints for single nodes (see WithHints)
+	Hints Hints
+
+	// Calls, signatures, return statements and chains of binary
+	// expressions that would exceed this line width are split into
+	// one argument, parameter, result or operand per line.
+	// 0 disables the wrapping (see WithMaxLineWidth).
+	MaxLineWidth int
+
+	// Width of *ast.BadExpr, *ast.BadStmt and *ast.BadDecl nodes.
+	// 0 selects the width of the placeholder that go/printer prints
+	// for them (see WithBadNodeWidth).
+	BadNodeWidth int
+
+	// Origin locations of declarations, written as //line
+	// directives (see WithLineDirectives)
+	LineDirectives LineDirectives
+
+	// Controls the blank lines between top-level declarations
+	BlankLines BlankLinePolicy
+
+	// Nodes of statement lists that are followed by a blank line
+	// (see PreserveBlankLines)
+	BlankLinesAfter map[ast.Node]bool
+
+	// Name of the synthetic file in the FileSet, "x.go" if empty
+	Filename string
+
+	// Called on every *ast.BasicLit right before its position is set
+	// (see WithBasicLitRewriter)
+	BasicLitRewriter func(*ast.BasicLit)
+
+	// FileSet that the file was parsed with (see WithOriginalFileSet)
+	OriginalFileSet *token.FileSet
+
+	// Comments anchored before the file was modified,
+	// replaces the anchoring by OriginalFileSet (see ReanchorComments)
+	CommentAnchors *CommentAnchors
+
+	// Keeps the layout of unchanged top-level declarations,
+	// requires OriginalFileSet (see WithPreservedPositions)
+	PreserveUnchanged bool
+
+	// Sorts the entries of map literals by their literal keys
+	// (see WithSortedMapKeys)
+	SortMapKeys bool
+
+	// Reorders and groups the fields of the struct types
+	// named in FieldOrderTypes (see WithFieldOrder)
+	FieldOrder      FieldOrder
+	FieldOrderTypes []string
+
+	// Keeps empty function bodies on one line
+	// (see WithCompactEmptyBodies)
+	CompactEmptyBodies bool
+
+	// Keeps function bodies with a single statement on one line
+	// if the line does not exceed this width (see WithCompactBodies)
+	CompactBodyWidth int
+
+	// Number of calls from which on method chains are broken
+	// into one call per line, 0 disables it (see WithChainThreshold)
+	ChainThreshold int
+
+	// String literals wider than this are split into one part per
+	// line, 0 disables it (see WithMaxStringWidth)
+	MaxStringWidth int
+
+	// Indents the lines and separates the tokens by spaces like
+	// gofmt, so that the positions have meaningful columns
+	// (see WithColumns)
+	Columns bool
+
+	// Separates all adjacent tokens by a space (see WithTokenSpacing)
+	TokenSpacing bool
+
+	// Separates the imports into groups of standard library,
+	// external and local imports (see WithImportGroups)
+	GroupImports bool
+
+	// Import path prefixes of the local imports
+	LocalImportPrefixes []string
+
+	// Follows the conventions of gofumpt (see WithGofumpt)
+	Gofumpt bool
+
+	// Runs goimports on the output of Format and Fprint (see WithImports)
+	ProcessImports bool
+
+	// Panics when a position moves backwards (see WithInvariantChecks)
+	CheckInvariants bool
+}
+
+// Layout of the elements of a composite literal
+type LiteralLayout int
+
+const (
+	// Decided by the number and kind of the elements
+	// (see CompositeLitThreshold)
+	LiteralLayoutDefault LiteralLayout = iota
+	// All elements on the lines of the braces, including
+	// nested composite literals with the default layout
+	LiteralLayoutInline
+	// Each element on its own line
+	LiteralLayoutOnePerLine
+)
+
+// Controls the blank lines between top-level declarations.
+// Note that go/format always separates declarations of different
+// kinds or with doc comments by a blank line.
+type BlankLinePolicy int
+
+const (
+	// Blank lines after function declarations only
+	BlankLinesDefault BlankLinePolicy = iota
+	// One blank line between all top-level declarations
+	BlankLinesAll
+	// No blank lines between top-level declarations
+	BlankLinesNone
+	// One blank line between all top-level declarations except
+	// for consecutive var, const, type or import declarations
+	// of the same kind, which form a group
+	BlankLinesGrouped
+)
+
+// Configures the behaviour of RewritePositions
+type Option func(*Options)
+
+func newOptions(opts []Option) Options {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// Sets a function that selects the layout of each composite literal.
+// Returning LiteralLayoutDefault keeps the default layout, e.g. to
+// only expand a specific literal.
+func WithCompositeLitLayout(layout func(*ast.CompositeLit) LiteralLayout) Option {
+	return func(o *Options) {
+		o.CompositeLitLayout = layout
+	}
+}
+
+// Sets the line width from which on calls are split into one argument
+// per line, function signatures into one parameter per line, return
+// statements into one result per line and chains of binary expressions
+// like a && b && c into one operand per line.
+// The width is estimated before formatting and does not include the
+// indentation of the line.
+func WithMaxLineWidth(width int) Option {
+	return func(o *Options) {
+		o.MaxLineWidth = width
+	}
+}
+
+// Makes the line breaks follow the conventions of gofumpt
+// (https://github.com/mvdan/gofumpt) on top of go/format:
+// standard library imports are grouped above all other imports
+// and small composite literals without nested composite literals
+// stay on one line. Blank lines at the start or end of a block are
+// never inserted, which gofumpt also requires.
+func WithGofumpt() Option {
+	return func(o *Options) {
+		o.Gofumpt = true
+	}
+}
+
+// Sets a function that is called on every *ast.BasicLit
+// right before its position is set. The function may alter
+// the literal's Value (e.g. to normalize quoting or number
+// formatting) and the position counter will use the
+// rewritten value.
+func WithBasicLitRewriter(rewrite func(*ast.BasicLit)) Option {
+	return func(o *Options) {
+		o.BasicLitRewriter = rewrite
+	}
+}
+
+// Sets the FileSet that the file was parsed with.
+// It is used to keep free floating comments (those that are not
+// the doc or line comment of a node) at their place between the
+// surrounding declarations, statements, specs or fields.
+// Without it these comments are dropped.
+func WithOriginalFileSet(fset *token.FileSet) Option {
+	return func(o *Options) {
+		o.OriginalFileSet = fset
+	}
+}
+
+// Sets the name of the synthetic file in the FileSet (default "x.go").
+// The name shows up in the positions that are resolved with the
+// FileSet, e.g. in error messages or panics.
+func WithFilename(name string) Option {
+	return func(o *Options) {
+		o.Filename = name
+	}
+}
+
+// Sets the policy for blank lines between top-level declarations
+func WithBlankLines(policy BlankLinePolicy) Option {
+	return func(o *Options) {
+		o.BlankLines = policy
+	}
+}
+
+func (o Options) filename() string {
+	if o.Filename == "" {
+		return "x.go"
+	}
+	return o.Filename
+}
+
+// Returns true if a composite literal with the given
+// number of elements should be split over multiple lines
+func (o Options) isMultiline(elements int) bool {
+	switch {
+	case o.CompositeLitThreshold < 0:
+		return false
+	case o.CompositeLitThreshold == 0:
+		return elements >= o.layout().compositeLitThreshold
+	}
+	return elements >= o.CompositeLitThreshold
+}
+
+// Keeps empty function bodies on one line, e.g. func F() {} for
+// generated stubs. Other empty blocks like those of if statements and
+// loops are always printed over two lines by go/printer.
+func WithCompactEmptyBodies() Option {
+	return func(o *Options) {
+		o.CompactEmptyBodies = true
+	}
+}
+
+// Indents the lines and separates the tokens by spaces like gofmt,
+// so that the positions resolve to sensible line:column values,
+// e.g. for diagnostics or debuggers. Line comments of var and const
+// blocks as well as the types, tags and line comments of struct fields
+// are aligned like gofmt aligns them.
+func WithColumns() Option {
+	return func(o *Options) {
+		o.Columns = true
+	}
+}
+
+// Separates all adjacent tokens by at least one space, so that the
+// positions describe a valid layout without the spacing of go/format,
+// e.g. when the AST is printed with go/printer directly.
+func WithTokenSpacing() Option {
+	return func(o *Options) {
+		o.TokenSpacing = true
+	}
+}
+
+// Sets the width of bad nodes (*ast.BadExpr, *ast.BadStmt and
+// *ast.BadDecl), e.g. the width of the source text that will replace
+// them. By default they are as wide as the placeholder text (BadExpr,
+// BadStmt or BadDecl) that go/printer prints for them.
+// RewritePositionsChecked reports bad nodes as errors.
+func WithBadNodeWidth(width int) Option {
+	return func(o *Options) {
+		o.BadNodeWidth = width
+	}
+}
@@ -0,0 +1,622 @@
+package astpos
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRewritePositionsWithOptions(t *testing.T) {
+	src := `package astpos
+
+	import "fmt"
+
+	var a = []int{1, 2}
+	var b = []int{1, 2, 3, 4, 5}
+	type T struct {
+		A int
+	}
+	func f() {
+		fmt.Println(a, b)
+	}
+	`
+
+	expected := `package astpos
+
+import "fmt"
+
+var a = []int{
+	1, 2,
+}
+
+var b = []int{
+	1, 2, 3, 4, 5,
+}
+
+type T struct {
+	A int
+}
+
+func f() {
+	fmt.Println(a, b)
+}
+`
+
+	opts := Options{
+		CompositeLitThreshold: 2,
+		BlankLines:            BlankLinesAll,
+		Filename:              "generated.go",
+	}
+	f, fset := RewritePositionsWithOptions(parseSource(t, src), opts)
+	if name := fset.File(f.Pos()).Name(); name != "generated.go" {
+		t.Errorf("file is named %q instead of %q", name, "generated.go")
+	}
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestCompositeLitThresholdDisabled(t *testing.T) {
+	src := `package astpos
+
+	var b = []int{1, 2, 3, 4, 5}
+	`
+
+	expected := `package astpos
+
+var b = []int{1, 2, 3, 4, 5}
+`
+
+	opts := Options{CompositeLitThreshold: -1}
+	f, fset := RewritePositionsWithOptions(parseSource(t, src), opts)
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestWithFilename(t *testing.T) {
+	src := `package astpos
+
+	var a = 1
+	`
+
+	f, fset := RewritePositions(parseSource(t, src), WithFilename("gen/a_gen.go"))
+	pos := fset.Position(f.Decls[0].Pos())
+	if pos.Filename != "gen/a_gen.go" || pos.Line != 2 {
+		t.Errorf("declaration is at %s instead of gen/a_gen.go:2", pos)
+	}
+}
+
+func TestBlankLinePolicies(t *testing.T) {
+	src := `package astpos
+
+	var a = 1
+	var b = 2
+	const c = 3
+	func f() int { return 1 }
+	func g() int { return 2 }
+	`
+
+	expected := map[BlankLinePolicy]string{
+		BlankLinesDefault: `package astpos
+
+var a = 1
+var b = 2
+
+const c = 3
+
+func f() int {
+	return 1
+}
+
+func g() int {
+	return 2
+}
+`,
+		BlankLinesAll: `package astpos
+
+var a = 1
+
+var b = 2
+
+const c = 3
+
+func f() int {
+	return 1
+}
+
+func g() int {
+	return 2
+}
+`,
+		BlankLinesNone: `package astpos
+
+var a = 1
+var b = 2
+
+const c = 3
+
+func f() int {
+	return 1
+}
+func g() int {
+	return 2
+}
+`,
+		BlankLinesGrouped: `package astpos
+
+var a = 1
+var b = 2
+
+const c = 3
+
+func f() int {
+	return 1
+}
+
+func g() int {
+	return 2
+}
+`,
+	}
+
+	for policy, expected := range expected {
+		f, fset := RewritePositions(parseSource(t, src), WithBlankLines(policy))
+		checkResult(t, writeAST(t, f, fset), expected)
+	}
+}
+
+func TestBlankLinesGrouped(t *testing.T) {
+	// Parenthesized declarations are not part of a group
+	src := `package astpos
+
+	var a = 1
+	var (
+		b = 2
+	)
+	var c = 3
+	var d = 4
+	`
+
+	expected := map[BlankLinePolicy]string{
+		BlankLinesDefault: `package astpos
+
+var a = 1
+var (
+	b = 2
+)
+var c = 3
+var d = 4
+`,
+		BlankLinesGrouped: `package astpos
+
+var a = 1
+
+var (
+	b = 2
+)
+
+var c = 3
+var d = 4
+`,
+	}
+
+	for policy, expected := range expected {
+		f, fset := RewritePositions(parseSource(t, src), WithBlankLines(policy))
+		checkResult(t, writeAST(t, f, fset), expected)
+	}
+}
+
+func TestGofumpt(t *testing.T) {
+	src := `package astpos
+
+	import (
+		"github.com/snonky/astpos/astpos"
+		"fmt"
+		"go/ast"
+	)
+
+	var m = map[string]int{"a": 1, "b": 2}
+	var points = []ast.Node{&ast.Ident{Name: "x"}, &ast.Ident{Name: "y"}}
+	var large = []int{1, 2, 3, 4}
+
+	func f() {
+		fmt.Println(m, points, large, astpos.Format)
+	}
+	`
+
+	expected := `package astpos
+
+import (
+	"fmt"
+	"go/ast"
+
+	"github.com/snonky/astpos/astpos"
+)
+
+var m = map[string]int{"a": 1, "b": 2}
+var points = []ast.Node{
+	&ast.Ident{Name: "x"},
+	&ast.Ident{Name: "y"},
+}
+var large = []int{
+	1, 2, 3, 4,
+}
+
+func f() {
+	fmt.Println(m, points, large, astpos.Format)
+}
+`
+
+	// Printed without goimports, which would group the imports itself
+	f, fset := RewritePositions(parseSource(t, src), WithGofumpt())
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, buf.String(), expected)
+}
+
+func TestMaxLineWidth(t *testing.T) {
+	src := `package astpos
+
+	func f() {
+		register("a-rather-long-name", handleTheRequest, withRetries(3), withTimeout(30), true)
+		register("short", handle)
+	}
+	`
+
+	expected := `package astpos
+
+func f() {
+	register(
+		"a-rather-long-name",
+		handleTheRequest,
+		withRetries(3),
+		withTimeout(30),
+		true,
+	)
+	register("short", handle)
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src), WithMaxLineWidth(60))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestMaxLineWidthSignatures(t *testing.T) {
+	src := `package astpos
+
+	type Service interface {
+		Create(name string, owner string, size int, labels map[string]string) (string, error)
+		Get(id string) error
+	}
+
+	func (s *server) Delete(id string, owner string, force bool, reason string) error {
+		return nil
+	}
+	`
+
+	expected := `package astpos
+
+type Service interface {
+	Create(
+		name string,
+		owner string,
+		size int,
+		labels map[string]string,
+	) (string, error)
+	Get(id string) error
+}
+
+func (s *server) Delete(
+	id string,
+	owner string,
+	force bool,
+	reason string,
+) error {
+	return nil
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src), WithMaxLineWidth(60))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestMaxLineWidthReturns(t *testing.T) {
+	src := `package astpos
+
+	func f() (string, int, error) {
+		if ok {
+			return "a-rather-long-result", computeTheCount(items), newError("failed")
+		}
+		return "", 0, nil
+	}
+	`
+
+	expected := `package astpos
+
+func f() (string, int, error) {
+	if ok {
+		return "a-rather-long-result",
+			computeTheCount(items),
+			newError("failed")
+	}
+	return "", 0, nil
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src), WithMaxLineWidth(60))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestMaxLineWidthBinaryChains(t *testing.T) {
+	src := `package astpos
+
+	func f() bool {
+		if isValid(request) && hasPermission(user, resource) && !isRateLimited(user) {
+			total := firstComponent + secondComponent*factor + thirdComponent
+			return total > 0 || request.Force && user.IsAdmin()
+		}
+		return a && b
+	}
+	`
+
+	expected := `package astpos
+
+func f() bool {
+	if isValid(request) &&
+		hasPermission(user, resource) &&
+		!isRateLimited(user) {
+		total := firstComponent +
+			secondComponent*factor +
+			thirdComponent
+		return total > 0 ||
+			request.Force && user.IsAdmin()
+	}
+	return a && b
+}
+`
+
+	f, fset := RewritePositions(parseSource(t, src), WithMaxLineWidth(40))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestCompactEmptyBodies(t *testing.T) {
+	src := `package astpos
+
+	func (s *stub) Close() {}
+
+	func f() {
+		go func() {}()
+		if ok {}
+		var g = func() {
+			// Comment
+		}
+	}
+	`
+
+	expected := `package astpos
+
+func (s *stub) Close() {}
+
+func f() {
+	go func() {}()
+	if ok {
+	}
+	var g = func() {
+		// Comment
+	}
+}
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A body with a comment is not empty
+	f, fset = RewritePositions(f, WithCompactEmptyBodies(), WithOriginalFileSet(fset))
+	checkResult(t, writeAST(t, f, fset), expected)
+
+	fn := f.Decls[0].(*ast.FuncDecl)
+	if fset.Position(fn.Body.Rbrace).Line != fset.Position(fn.Pos()).Line {
+		t.Errorf("The empty body of %s is not on the line of its signature", fn.Name.Name)
+	}
+}
+
+func TestCompositeLitLayout(t *testing.T) {
+	src := `package astpos
+
+	var expanded = Options{Filename: "a.go", BlankLines: 1}
+	var inline = map[string]int{"a": 1, "b": 2}
+	var nested = [][]int{{1, 2}, {3, 4}}
+	var unchanged = []int{1, 2, 3, 4}
+	`
+
+	expected := `package astpos
+
+var expanded = Options{
+	Filename:   "a.go",
+	BlankLines: 1,
+}
+var inline = map[string]int{"a": 1, "b": 2}
+var nested = [][]int{
+	{1, 2},
+	{3, 4},
+}
+var unchanged = []int{
+	1, 2, 3, 4,
+}
+`
+
+	f := parseSource(t, src)
+	byName := map[string]LiteralLayout{
+		"expanded": LiteralLayoutOnePerLine,
+		"inline":   LiteralLayoutInline,
+	}
+	layouts := make(map[*ast.CompositeLit]LiteralLayout)
+	for _, decl := range f.Decls {
+		spec := decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+		layouts[spec.Values[0].(*ast.CompositeLit)] = byName[spec.Names[0].Name]
+	}
+
+	f, fset := RewritePositions(f, WithCompositeLitLayout(func(lit *ast.CompositeLit) LiteralLayout {
+		return layouts[lit]
+	}))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestColumns(t *testing.T) {
+	src := `package astpos
+
+import "fmt"
+
+type T struct {
+	a int
+}
+
+var (
+	x = 1
+	y = []int{1}
+)
+
+func f(a int) int {
+	x := a + 1
+	if x > 2 {
+		for x < 10 {
+			x += a
+		}
+	}
+	switch x {
+	case 1:
+		return x
+	}
+	select {
+	case c <- x:
+	}
+	fmt.Println(t.a.b)
+L:
+	for {
+		if x > 3 {
+			continue L
+		}
+		if x > 4 {
+			break L
+		}
+		goto L
+	}
+	return a
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := nodeColumns(f, fset)
+
+	f, fset = RewritePositions(f, WithColumns())
+	if result := nodeColumns(f, fset); result != expected {
+		t.Fatalf("Columns differ from the parsed source:\n%s\nexpected:\n%s", result, expected)
+	}
+	if result := writeAST(t, f, fset); result != src {
+		t.Fatalf("The re-formatted source code differs from the expected outcome:\n%s", result)
+	}
+}
+
+func TestFuncDeclColumns(t *testing.T) {
+	src := `package astpos
+
+type S struct {
+	f func(a, b int) (int, error)
+}
+
+type T int
+
+func (s S) M(a int) string {
+	return ""
+}
+
+func (t *T) N() {
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := nodeColumns(f, fset)
+
+	// The func keyword of a method comes before its receiver, the
+	// parameters of a func typed field stay on its line and each
+	// declaration ends its line
+	f, fset = RewritePositions(f, WithColumns())
+	if result := nodeColumns(f, fset); result != expected {
+		t.Fatalf("Columns differ from the parsed source:\n%s\nexpected:\n%s", result, expected)
+	}
+	for _, decl := range f.Decls[2:] {
+		fn := decl.(*ast.FuncDecl)
+		if fn.Type.Func >= fn.Recv.Pos() {
+			t.Errorf("func keyword of %s at %d does not precede its receiver", fn.Name.Name, fn.Type.Func)
+		}
+	}
+	if result := writeAST(t, f, fset); result != src {
+		t.Fatalf("The re-formatted source code differs from the expected outcome:\n%s", result)
+	}
+}
+
+// Returns the columns of all nodes
+func nodeColumns(f *ast.File, fset *token.FileSet) string {
+	var columns []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n != nil {
+			columns = append(columns, fmt.Sprintf("%T %d", n, fset.Position(n.Pos()).Column))
+		}
+		return n != nil
+	})
+	return strings.Join(columns, "\n")
+}
+
+func TestTokenSpacing(t *testing.T) {
+	f, fset := RewritePositions(parseSource(t, sampleSource))
+	expected := writeAST(t, f, fset)
+
+	f, fset = RewritePositions(parseSource(t, sampleSource), WithTokenSpacing())
+	var leaves []ast.Node
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.Ident, *ast.BasicLit:
+			leaves = append(leaves, n)
+		}
+		return n != nil
+	})
+	slices.SortFunc(leaves, func(a, b ast.Node) int {
+		return int(a.Pos() - b.Pos())
+	})
+	for i := 1; i < len(leaves); i++ {
+		if prev, next := leaves[i-1], leaves[i]; next.Pos() <= prev.End() {
+			t.Errorf("%s at %s is not separated from %s", nodeString(next), fset.Position(next.Pos()), nodeString(prev))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "x.go", buf.Bytes(), 0); err != nil {
+		t.Fatalf("Output of go/printer does not parse: %v\n%s", err, buf.String())
+	}
+	if result := writeAST(t, f, fset); result != expected {
+		t.Fatalf("The re-formatted source code differs from the one without spacing:\n%s", result)
+	}
+}
+
+func nodeString(n ast.Node) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), n)
+	return buf.String()
+}
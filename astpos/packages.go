@@ -0,0 +1,32 @@
+package astpos
+
+import (
+	"fmt"
+	"go/token"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Rewrites the positions of the files of a package loaded with
+// go/packages (requires packages.NeedSyntax) into one new FileSet.
+// The top-level declarations that are unchanged since loading keep
+// their original layout (see WithPreservedPositions), only the
+// synthesized or modified ones are laid out anew.
+// The files keep their names and their order in pkg.Syntax.
+func RewriteLoadedPackage(pkg *packages.Package, opts ...Option) (*token.FileSet, error) {
+	if pkg.Fset == nil || len(pkg.Syntax) == 0 {
+		return nil, fmt.Errorf("astpos: package %s was loaded without syntax", pkg.PkgPath)
+	}
+
+	fset := token.NewFileSet()
+	options := newOptions(append([]Option{WithPreservedPositions(pkg.Fset)}, opts...))
+	for _, f := range pkg.Syntax {
+		options.Filename = ""
+		if file := pkg.Fset.File(f.FileStart); file != nil {
+			options.Filename = file.Name()
+		}
+		p := newPositioner(f, fset, options)
+		p.positionTokens()
+	}
+	return fset, nil
+}
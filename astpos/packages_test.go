@@ -0,0 +1,81 @@
+package astpos
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestRewriteLoadedPackage(t *testing.T) {
+	sources := map[string]string{
+		"a.go": `package astpos
+
+// A is unchanged
+func A() []int {
+	return []int{1,
+		2}
+}
+`,
+		"b.go": `package astpos
+
+var b = A()
+`,
+	}
+
+	pkg := &packages.Package{PkgPath: "example.com/astpos", Fset: token.NewFileSet()}
+	for _, name := range []string{"a.go", "b.go"} {
+		f, err := parser.ParseFile(pkg.Fset, name, sources[name], parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg.Syntax = append(pkg.Syntax, f)
+	}
+
+	// A synthesized declaration
+	pkg.Syntax[1].Decls = append(pkg.Syntax[1].Decls, &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{
+			Names: []*ast.Ident{ast.NewIdent("c")},
+			Values: []ast.Expr{&ast.CompositeLit{Type: ast.NewIdent("T"), Elts: []ast.Expr{
+				&ast.KeyValueExpr{Key: ast.NewIdent("X"), Value: ast.NewIdent("b")},
+				&ast.KeyValueExpr{Key: ast.NewIdent("Y"), Value: ast.NewIdent("b")},
+			}}},
+		}},
+	})
+
+	fset, err := RewriteLoadedPackage(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"a.go": sources["a.go"],
+		"b.go": `package astpos
+
+var b = A()
+var c = T{
+	X: b,
+	Y: b,
+}
+`,
+	}
+	for _, f := range pkg.Syntax {
+		name := fset.File(f.Pos()).Name()
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, f); err != nil {
+			t.Fatal(err)
+		}
+		checkResult(t, buf.String(), expected[name])
+	}
+}
+
+func TestRewriteLoadedPackageWithoutSyntax(t *testing.T) {
+	if _, err := RewriteLoadedPackage(&packages.Package{PkgPath: "example.com/astpos"}); err == nil {
+		t.Error("expected an error for a package without syntax")
+	}
+}
@@ -0,0 +1,61 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// Wraps the operands of the expressions in the node in an
+// *ast.ParenExpr wherever operator precedence would otherwise change
+// the meaning of the tree, e.g. the sum in (a + b) * c. go/printer
+// prints these parentheses anyway, but only a tree that contains them
+// gets positions that match the printed code. Existing parentheses
+// are kept.
+func Parenthesize(n ast.Node) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BinaryExpr:
+			// Binary operators are left-associative
+			prec := n.Op.Precedence()
+			n.X = parenBelow(n.X, prec)
+			n.Y = parenBelow(n.Y, prec+1)
+		case *ast.UnaryExpr:
+			n.X = parenBelow(n.X, token.UnaryPrec)
+		case *ast.StarExpr:
+			n.X = parenBelow(n.X, token.UnaryPrec)
+		case *ast.SelectorExpr:
+			n.X = parenBelow(n.X, token.HighestPrec)
+		case *ast.CallExpr:
+			n.Fun = parenBelow(n.Fun, token.HighestPrec)
+		case *ast.IndexExpr:
+			n.X = parenBelow(n.X, token.HighestPrec)
+		case *ast.IndexListExpr:
+			n.X = parenBelow(n.X, token.HighestPrec)
+		case *ast.SliceExpr:
+			n.X = parenBelow(n.X, token.HighestPrec)
+		case *ast.TypeAssertExpr:
+			n.X = parenBelow(n.X, token.HighestPrec)
+		}
+		return true
+	})
+}
+
+// Wraps the expression in parentheses if it binds weaker than prec
+func parenBelow(x ast.Expr, prec int) ast.Expr {
+	if x != nil && precedence(x) < prec {
+		return &ast.ParenExpr{X: x}
+	}
+	return x
+}
+
+// Returns the precedence of the operator of the expression,
+// token.HighestPrec for operands
+func precedence(x ast.Expr) int {
+	switch x := x.(type) {
+	case *ast.BinaryExpr:
+		return x.Op.Precedence()
+	case *ast.UnaryExpr, *ast.StarExpr:
+		return token.UnaryPrec
+	}
+	return token.HighestPrec
+}
@@ -0,0 +1,56 @@
+package astpos
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestParenthesize(t *testing.T) {
+	id := ast.NewIdent
+	bin := func(x ast.Expr, op token.Token, y ast.Expr) ast.Expr {
+		return &ast.BinaryExpr{X: x, Op: op, Y: y}
+	}
+	tests := []struct {
+		expr     ast.Expr
+		expected string
+	}{
+		{bin(bin(id("a"), token.ADD, id("b")), token.MUL, id("c")), "(a + b) * c"},
+		{bin(id("a"), token.SUB, bin(id("b"), token.SUB, id("c"))), "a - (b - c)"},
+		{bin(bin(id("a"), token.SUB, id("b")), token.SUB, id("c")), "a - b - c"},
+		{bin(id("a"), token.ADD, bin(id("b"), token.MUL, id("c"))), "a + b*c"},
+		{bin(bin(id("a"), token.LOR, id("b")), token.LAND, id("c")), "(a || b) && c"},
+		{&ast.UnaryExpr{Op: token.NOT, X: bin(id("a"), token.EQL, id("b"))}, "!(a == b)"},
+		{&ast.SelectorExpr{X: &ast.UnaryExpr{Op: token.AND, X: id("t")}, Sel: id("f")}, "(&t).f"},
+		{&ast.CallExpr{Fun: &ast.StarExpr{X: id("T")}, Args: []ast.Expr{id("x")}}, "(*T)(x)"},
+		{&ast.ParenExpr{X: bin(id("a"), token.ADD, id("b"))}, "(a + b)"},
+	}
+	for _, test := range tests {
+		Parenthesize(test.expr)
+		node, fset := RewriteNode(test.expr)
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, node); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("Expression printed as %s, expected %s", buf.String(), test.expected)
+		}
+
+		// The tree matches the one of the printed expression
+		parsed, err := parser.ParseExpr(buf.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ClearPositions(parsed)
+		ClearPositions(test.expr)
+		var want, got bytes.Buffer
+		ast.Fprint(&want, nil, parsed, ast.NotNilFilter)
+		ast.Fprint(&got, nil, test.expr, ast.NotNilFilter)
+		if got.String() != want.String() {
+			t.Errorf("Tree of %s differs from the parsed tree:\n%s", buf.String(), got.String())
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// Parses the expression and clears its positions, so it can be
+// inserted into a generated file. Panics if the source is not a
+// valid expression.
+func MustParseExpr(src string) ast.Expr {
+	expr, err := parser.ParseExpr(src)
+	if err != nil {
+		panic("astpos: " + err.Error())
+	}
+	ClearPositions(expr)
+	return expr
+}
+
+// Parses the statements as the body of a function and clears their
+// positions, so they can be inserted into a generated file.
+// Comments are dropped. Panics if the source is not a valid list of
+// statements.
+func MustParseStmts(src string) []ast.Stmt {
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package p; func _() {\n"+src+"\n}", parser.SkipObjectResolution)
+	if err != nil {
+		panic("astpos: " + err.Error())
+	}
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	ClearPositions(body)
+	return body.List
+}
@@ -0,0 +1,33 @@
+package astpos
+
+import (
+	"go/ast"
+	"testing"
+)
+
+func TestMustParse(t *testing.T) {
+	f := parseSource(t, "package main\n\nfunc f() {\n}\n")
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	body.List = MustParseStmts("x := 1\nif x > 0 {\n\tx++\n}")
+	body.List = append(body.List, &ast.ExprStmt{X: MustParseExpr("println(x)")})
+	ClearPositions(f)
+
+	f, fset := RewritePositions(f)
+	checkResult(t, writeAST(t, f, fset), "package main\n\nfunc f() {\n\tx := 1\n\tif x > 0 {\n\t\tx++\n\t}\n\tprintln(x)\n}\n")
+}
+
+func TestMustParsePanics(t *testing.T) {
+	for _, parse := range []func(){
+		func() { MustParseExpr("x +") },
+		func() { MustParseStmts("x := ") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Invalid source did not panic")
+				}
+			}()
+			parse()
+		}()
+	}
+}
@@ -0,0 +1,126 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// Gives a NodeHandler access to the position counter of a rewrite.
+//
+// A Positioner created with NewPositioner can also rewrite many files
+// one after another. It keeps its buffers between the rewrites, which
+// saves allocations compared to calling RewritePositions for each file.
+// A Positioner must not be used by multiple goroutines at once.
+//
+// Rewrite positions independent subtrees one after another into the
+// same token.File of the Positioner's FileSet.
+type Positioner struct {
+	p *astPositioner
+
+	// FileSet of Rewrite and whether its last file is still open
+	fset *token.FileSet
+	open bool
+}
+
+// Returns a reusable Positioner with the given options
+func NewPositioner(opts ...Option) *Positioner {
+	return &Positioner{p: &astPositioner{opts: newOptions(opts)}}
+}
+
+// Same as RewritePositions with the options of the Positioner
+func (p *Positioner) RewritePositions(f *ast.File) (*ast.File, *token.FileSet) {
+	p.closeFile()
+	fset := token.NewFileSet()
+	p.p.init(f, fset)
+	p.p.positionTokens()
+	return f, fset
+}
+
+// Positions the node behind the node of the previous call, separated
+// by a blank line. The first call after FileSet or Reset starts a new
+// token.File. Files are rewritten with RewritePositions instead,
+// since Rewrite does not position their comments.
+func (p *Positioner) Rewrite(n ast.Node) {
+	if !p.open {
+		if p.fset == nil {
+			p.fset = token.NewFileSet()
+		}
+		p.p.init(n, p.fset)
+		p.open = true
+	} else {
+		p.p.root = n
+		p.p.blankLine()
+	}
+	p.p.traverse(n)
+}
+
+// Returns the FileSet of the nodes positioned by Rewrite.
+// Adds the current token.File to it, so the next call to Rewrite
+// starts a new file.
+func (p *Positioner) FileSet() *token.FileSet {
+	p.closeFile()
+	if p.fset == nil {
+		p.fset = token.NewFileSet()
+	}
+	return p.fset
+}
+
+func (p *Positioner) closeFile() {
+	if p.open {
+		p.p.shrinkFile()
+		p.open = false
+	}
+}
+
+// Drops all references to the last rewritten file and the FileSet
+// of Rewrite, so that they can be garbage collected
+func (p *Positioner) Reset() {
+	p.p.reset()
+	p.fset = nil
+	p.open = false
+}
+
+// Sets a function that is called on every node before its position
+// is set. If it returns true the node and its children count as
+// positioned, otherwise the node is positioned as usual.
+// This way custom ast.Node implementations (wrapper nodes,
+// placeholders, ...) can assign their own positions.
+func WithNodeHandler(handler func(p *Positioner, n ast.Node) bool) Option {
+	return func(o *Options) {
+		o.NodeHandler = handler
+	}
+}
+
+// Returns the current position
+func (p *Positioner) Pos() token.Pos {
+	return p.p.pc()
+}
+
+// Moves the current position past the text
+func (p *Positioner) Move(text string) {
+	p.p.moveText(text)
+}
+
+// Moves the current position past the token
+func (p *Positioner) MoveToken(t token.Token) {
+	p.p.move(t)
+}
+
+// Starts a new line
+func (p *Positioner) Newline() {
+	p.p.newline()
+}
+
+// Positions the node and its children, e.g. the children
+// of a custom node. The node handler is called for them too.
+func (p *Positioner) Traverse(n ast.Node) {
+	p.p.traverse(n)
+}
+
+// Calls the node handler and returns true if it handled the node
+func (p *astPositioner) handleNode(n ast.Node) bool {
+	if p.opts.NodeHandler == nil {
+		return false
+	}
+	return p.opts.NodeHandler(&Positioner{p: p}, n)
+}
@@ -0,0 +1,133 @@
+package astpos
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"testing"
+)
+
+// A wrapper node that go/ast does not know
+type placeholder struct {
+	*ast.Ident
+	text string
+}
+
+func TestNodeHandler(t *testing.T) {
+	f := parseSource(t, `package astpos
+
+	var x = f(a, b)
+	`)
+	call := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0].(*ast.CallExpr)
+	ph := placeholder{Ident: ast.NewIdent("a"), text: "/* a */"}
+	call.Args[0] = ph
+
+	var handled int
+	RewritePositions(f, WithNodeHandler(func(p *Positioner, n ast.Node) bool {
+		ph, ok := n.(placeholder)
+		if !ok {
+			return false
+		}
+		handled++
+		ph.NamePos = p.Pos()
+		p.Move(ph.text)
+		return true
+	}))
+
+	if handled != 1 {
+		t.Fatalf("handler was called %d times for the placeholder", handled)
+	}
+	b := call.Args[1].(*ast.Ident)
+	if want := ph.NamePos + token.Pos(len(ph.text)+len(",")); b.Pos() != want {
+		t.Errorf("argument after the placeholder and comma at %d, expected %d", b.Pos(), want)
+	}
+}
+
+func TestPositionerReuse(t *testing.T) {
+	f, fset := RewritePositions(parseSource(t, sampleSource))
+	expected := writeAST(t, f, fset)
+
+	p := NewPositioner()
+	for range 3 {
+		f, fset := p.RewritePositions(parseSource(t, sampleSource))
+		if result := writeAST(t, f, fset); result != expected {
+			t.Fatalf("Reused positioner differs from RewritePositions:\n%s", result)
+		}
+		p.Reset()
+	}
+}
+
+func TestPositionerRewrite(t *testing.T) {
+	f := parseSource(t, "package p\n\nfunc f() {\n\tx := 1\n}\n\nvar y = 2\n")
+	ClearPositions(f)
+	fn, decl := f.Decls[0], f.Decls[1]
+
+	p := NewPositioner()
+	p.Rewrite(fn)
+	p.Rewrite(decl)
+	fset := p.FileSet()
+
+	if fn.End() >= decl.Pos() {
+		t.Errorf("Second node starts at %d before the end of the first at %d", decl.Pos(), fn.End())
+	}
+	file := fset.File(fn.Pos())
+	if file == nil || file != fset.File(decl.Pos()) {
+		t.Fatal("Nodes are not in the same file")
+	}
+	if line := fset.Position(decl.Pos()).Line; line != 5 {
+		t.Errorf("Second node is on line %d, expected 5", line)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, decl); err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, buf.String(), "var y = 2")
+
+	// The next node starts a new file
+	p.Rewrite(ast.NewIdent("z"))
+	if fset := p.FileSet(); fset.File(fn.Pos()) != file || fset.Base() <= file.Base()+file.Size() {
+		t.Error("The closed file was not kept")
+	}
+}
+
+func TestPositionerContext(t *testing.T) {
+	src := `package astpos
+
+	type S struct {
+		a int
+		f func(b int) interface{ M(c int) }
+	}
+
+	func (r R) m() {}
+
+	func g[T any](d T) {
+		e := 1
+	}
+	`
+	expected := map[string]Context{
+		"a": ContextStruct,
+		"b": ContextParams,
+		"M": ContextInterface,
+		"c": ContextParams,
+		"T": ContextTypeParams,
+		"d": ContextParams,
+		"e": ContextNone,
+		"r": ContextParams,
+	}
+
+	contexts := make(map[string]Context)
+	RewritePositions(parseSource(t, src), WithNodeHandler(func(p *Positioner, n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if _, seen := contexts[id.Name]; !seen {
+				contexts[id.Name] = p.Context()
+			}
+		}
+		return false
+	}))
+	for name, context := range expected {
+		if contexts[name] != context {
+			t.Errorf("%s is in context %d, expected %d", name, contexts[name], context)
+		}
+	}
+}
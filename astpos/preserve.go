@@ -0,0 +1,152 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+)
+
+// Keeps the layout of the top-level declarations that are unchanged
+// since the file was parsed with the given FileSet. Only declarations
+// that were added or contain nodes with missing (token.NoPos) or
+// inconsistent positions are laid out anew.
+// Unchanged declarations keep their original line breaks and are
+// only shifted as a whole, which keeps the diff to the original
+// source minimal.
+//
+// Also sets the original FileSet (see WithOriginalFileSet).
+func WithPreservedPositions(fset *token.FileSet) Option {
+	return func(o *Options) {
+		o.OriginalFileSet = fset
+		o.PreserveUnchanged = true
+	}
+}
+
+// Original extent of an unchanged top-level declaration
+type unchangedDecl struct {
+	// Start of the declaration including its doc comment
+	start token.Pos
+	// End of the declaration including comments on its last line
+	end token.Pos
+
+	// Originally followed by an empty line
+	blankAfter bool
+
+	// Comment groups within the extent
+	comments []*ast.CommentGroup
+}
+
+// Finds the top-level declarations whose positions are
+// consistent with the original file.
+// Must be called before the positions are rewritten.
+func (p *astPositioner) collectUnchangedDecls() {
+	p.unchanged = make(map[ast.Node]unchangedDecl)
+	fset := p.opts.OriginalFileSet
+	for i, decl := range p.file.Decls {
+		if !p.isUnchanged(decl) {
+			continue
+		}
+		u := unchangedDecl{start: nodeStart(decl), end: decl.End()}
+		endLine := fset.Position(u.end).Line
+		for _, c := range p.file.Comments {
+			if c.Pos() >= u.end && fset.Position(c.Pos()).Line == endLine {
+				u.end = c.End()
+			}
+		}
+		for _, c := range p.file.Comments {
+			if u.start <= c.Pos() && c.End() <= u.end {
+				u.comments = append(u.comments, c)
+			}
+		}
+		if i+1 < len(p.file.Decls) {
+			if next := nodeStart(p.file.Decls[i+1]); next.IsValid() {
+				u.blankAfter = fset.Position(next).Line-endLine > 1
+			}
+		}
+		p.unchanged[decl] = u
+	}
+}
+
+// Returns true if the positions of all nodes of the declaration are
+// consistent (see Verify) and lie within its original extent. Renamed
+// identifiers and literals that grew overlap the following nodes.
+func (p *astPositioner) isUnchanged(decl ast.Decl) bool {
+	start, end := nodeStart(decl), decl.End()
+	if !start.IsValid() || end < start {
+		return false
+	}
+	origFile := p.opts.OriginalFileSet.File(start)
+	if origFile == nil || int(end) > origFile.Base()+origFile.Size() {
+		return false
+	}
+
+	v := verifier{fset: p.opts.OriginalFileSet}
+	ast.Inspect(decl, v.visit)
+	return len(v.errs) == 0
+}
+
+// Returns true if the comment group lies within an unchanged declaration
+func (p *astPositioner) inUnchangedDecl(c *ast.CommentGroup) bool {
+	for _, u := range p.unchanged {
+		if u.start <= c.Pos() && c.End() <= u.end {
+			return true
+		}
+	}
+	return false
+}
+
+// Moves the unchanged declaration to the current position and
+// copies its original line breaks
+func (p *astPositioner) copyDecl(decl ast.Node, u unchangedDecl) {
+	origFile := p.opts.OriginalFileSet.File(decl.Pos())
+
+	p.endLine()
+	startLine := origFile.Line(u.start)
+	delta := p.p - int(origFile.LineStart(startLine))
+	for line := startLine + 1; line <= origFile.Line(u.end); line++ {
+		p.AddLine(int(origFile.LineStart(line)) + delta - p.Base())
+	}
+
+	shiftPositions(decl, delta)
+	for _, c := range u.comments {
+		for _, comment := range c.List {
+			comment.Slash += token.Pos(delta)
+		}
+		p.comments = append(p.comments, c)
+	}
+
+	p.p = int(u.end) + delta
+	p.newline()
+	if u.blankAfter {
+		p.newline()
+	}
+}
+
+// Adds delta to all valid positions of the node and its children,
+// except for the comments
+func shiftPositions(n ast.Node, delta int) {
+	posType := reflect.TypeOf(token.NoPos)
+	ast.Inspect(n, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if _, ok := n.(*ast.CommentGroup); ok {
+			return false
+		}
+		v := reflect.ValueOf(n)
+		if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return true
+		}
+		v = v.Elem()
+		for i := range v.NumField() {
+			field := v.Field(i)
+			if field.Type() != posType || !field.CanSet() {
+				continue
+			}
+			if pos := token.Pos(field.Int()); pos.IsValid() {
+				field.SetInt(int64(pos) + int64(delta))
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,116 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestPreservedPositions(t *testing.T) {
+	src := `package astpos
+
+// Keep is unchanged
+func Keep() []int {
+	x := []int{1,
+		2}
+
+	return x // trailing
+}
+
+func Change() {
+	println("a")
+}
+
+var table = map[string]int{"a": 1, "b": 2,
+	"c": 3}
+`
+
+	expected := `package astpos
+
+// Keep is unchanged
+func Keep() []int {
+	x := []int{1,
+		2}
+
+	return x // trailing
+}
+
+func Change() {
+	println("a")
+	println("b")
+}
+
+var table = map[string]int{"a": 1, "b": 2,
+	"c": 3}
+var added = table
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change := f.Decls[1].(*ast.FuncDecl)
+	change.Body.List = append(change.Body.List, &ast.ExprStmt{X: &ast.CallExpr{
+		Fun:  ast.NewIdent("println"),
+		Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: `"b"`}},
+	}})
+	f.Decls = append(f.Decls, &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{
+			Names:  []*ast.Ident{ast.NewIdent("added")},
+			Values: []ast.Expr{ast.NewIdent("table")},
+		}},
+	})
+
+	f, fset = RewritePositions(f, WithPreservedPositions(fset))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestPreservedPositionsRenamed(t *testing.T) {
+	src := `package astpos
+
+func Rename() {
+	x := 1
+	println(x, "a")
+}
+`
+
+	expected := `package astpos
+
+func Rename() {
+	renamed := 1
+	println(renamed, "longer")
+}
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The longer names overlap the tokens that follow them
+	// and can not keep their original positions
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if n.Name == "x" {
+				n.Name = "renamed"
+			}
+		case *ast.BasicLit:
+			if n.Value == `"a"` {
+				n.Value = `"longer"`
+			}
+		}
+		return true
+	})
+
+	f, fset = RewritePositions(f, WithPreservedPositions(fset))
+	if err := Verify(f, fset); err != nil {
+		t.Error(err)
+	}
+	checkResult(t, writeAST(t, f, fset), expected)
+}
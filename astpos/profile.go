@@ -0,0 +1,58 @@
+package astpos
+
+// Named version of the layout heuristics (see WithProfile)
+type Profile int
+
+const (
+	// The newest heuristics, the output can change with
+	// new versions of astpos
+	ProfileLatest Profile = iota
+	// The heuristics at the introduction of profiles
+	ProfileV1
+	// Keeps anonymous struct types with a single field
+	// on one line, e.g. struct{ A int }{A: 1}
+	ProfileV2
+)
+
+// The profile that ProfileLatest selects
+const latestProfile = ProfileV2
+
+// Heuristics that differ between the profiles.
+// Changes to a heuristic add a new profile with the changed value,
+// the existing profiles keep their values.
+type layout struct {
+	// Default of Options.CompositeLitThreshold
+	compositeLitThreshold int
+
+	// Keep anonymous structs on one line if possible
+	inlineStructs bool
+}
+
+var layouts = [...]layout{
+	ProfileV1: {
+		compositeLitThreshold: 4,
+	},
+	ProfileV2: {
+		compositeLitThreshold: 4,
+		inlineStructs:         true,
+	},
+}
+
+// Locks the layout heuristics to the given profile, so that
+// improvements in later versions of astpos do not change the
+// generated code until the profile is updated. Options that are
+// set explicitly take precedence over the profile.
+func WithProfile(profile Profile) Option {
+	return func(o *Options) {
+		o.Profile = profile
+	}
+}
+
+// Returns the heuristics of the selected profile.
+// Unknown profiles select the latest one.
+func (o Options) layout() layout {
+	if o.Profile <= ProfileLatest || int(o.Profile) >= len(layouts) {
+		return layouts[latestProfile]
+	}
+	return layouts[o.Profile]
+}
@@ -0,0 +1,20 @@
+package astpos
+
+import "testing"
+
+func TestProfiles(t *testing.T) {
+	f, fset := RewritePositions(parseSource(t, sampleSource))
+	expected := writeAST(t, f, fset)
+
+	for _, profile := range []Profile{ProfileLatest, ProfileV1, Profile(-1), Profile(len(layouts))} {
+		f, fset := RewritePositions(parseSource(t, sampleSource), WithProfile(profile))
+		checkResult(t, writeAST(t, f, fset), expected)
+	}
+
+	// Explicit options take precedence
+	src := "package p\n\nvar x = []int{1, 2}\n"
+	f, fset = RewritePositions(parseSource(t, src), WithProfile(ProfileV1), func(o *Options) {
+		o.CompositeLitThreshold = 2
+	})
+	checkResult(t, writeAST(t, f, fset), "package p\n\nvar x = []int{\n\t1, 2,\n}\n")
+}
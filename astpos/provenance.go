@@ -0,0 +1,26 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// Returns the start position of every node of the rewritten tree,
+// e.g. to report where a synthesized node landed in the output.
+// The lines can be off by the blank lines that go/format inserts
+// on its own (e.g. after the package clause) and the columns are
+// only meaningful with WithColumns.
+// Nodes without a valid position are left out.
+func PositionMap(root ast.Node, fset *token.FileSet) map[ast.Node]token.Position {
+	positions := make(map[ast.Node]token.Position)
+	ast.Inspect(root, func(n ast.Node) bool {
+		if n == nil || isNil(n) {
+			return false
+		}
+		if pos := n.Pos(); pos.IsValid() {
+			positions[n] = fset.Position(pos)
+		}
+		return true
+	})
+	return positions
+}
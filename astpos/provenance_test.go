@@ -0,0 +1,35 @@
+package astpos
+
+import (
+	"go/ast"
+	"testing"
+)
+
+func TestPositionMap(t *testing.T) {
+	src := `package main
+
+	func f() {
+		x := 1
+		if x > 0 {
+			x++
+		}
+	}
+	`
+	f := parseSource(t, src)
+	fn := f.Decls[0].(*ast.FuncDecl)
+	inc := fn.Body.List[1].(*ast.IfStmt).Body.List[0]
+
+	f, fset := RewritePositions(f, WithColumns())
+	positions := PositionMap(f, fset)
+
+	pos, ok := positions[inc]
+	if !ok {
+		t.Fatal("Statement is missing from the map")
+	}
+	if pos.Line != 5 || pos.Column != 3 {
+		t.Errorf("Statement is at %d:%d, expected 5:3", pos.Line, pos.Column)
+	}
+	if positions[fn].Line >= pos.Line {
+		t.Errorf("Function on line %d does not precede its statement", positions[fn].Line)
+	}
+}
@@ -0,0 +1,94 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"slices"
+)
+
+// Sorts the entries of map literals by their keys while rewriting,
+// so that generated maps do not depend on the iteration order of the
+// generator. Only literals whose keys are all string, integer or
+// floating point literals of one kind are sorted, the order of all
+// other literals is kept.
+func WithSortedMapKeys() Option {
+	return func(o *Options) {
+		o.SortMapKeys = true
+	}
+}
+
+// Sorts the entries of the map literal of the given type if all of its
+// keys are basic literals of the same kind. Also sorts the nested
+// literals whose type is elided.
+func sortMapKeys(lit *ast.CompositeLit, typ ast.Expr) {
+	sortElidedMapKeys(lit, typ)
+	if _, ok := typ.(*ast.MapType); !ok || len(lit.Elts) < 2 {
+		return
+	}
+	keys := make(map[ast.Expr]constant.Value, len(lit.Elts))
+	var kind token.Token
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return
+		}
+		key, ok := kv.Key.(*ast.BasicLit)
+		if !ok || (kind != token.ILLEGAL && key.Kind != kind) {
+			return
+		}
+		kind = key.Kind
+		value := constant.MakeFromLiteral(key.Value, key.Kind, 0)
+		if value.Kind() == constant.Unknown {
+			return
+		}
+		keys[elt] = value
+	}
+	if kind != token.STRING && kind != token.INT && kind != token.FLOAT {
+		return
+	}
+	slices.SortStableFunc(lit.Elts, func(a, b ast.Expr) int {
+		switch {
+		case constant.Compare(keys[a], token.LSS, keys[b]):
+			return -1
+		case constant.Compare(keys[a], token.GTR, keys[b]):
+			return 1
+		}
+		return 0
+	})
+}
+
+// Sorts the keys of the elements of the literal whose type is elided,
+// which is the key or element type of the literal's type
+func sortElidedMapKeys(lit *ast.CompositeLit, typ ast.Expr) {
+	var keyType, eltType ast.Expr
+	switch t := typ.(type) {
+	case *ast.MapType:
+		keyType, eltType = t.Key, t.Value
+	case *ast.ArrayType:
+		eltType = t.Elt
+	default:
+		return
+	}
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			sortElided(kv.Key, keyType)
+			elt = kv.Value
+		}
+		sortElided(elt, eltType)
+	}
+}
+
+// Sorts the map literal x of the elided type typ, which may also
+// be a pointer type for &T{} abbreviated as {}
+func sortElided(x, typ ast.Expr) {
+	if star, ok := typ.(*ast.StarExpr); ok {
+		if unary, ok := x.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+			x = unary.X
+		}
+		typ = star.X
+	}
+	if lit, ok := x.(*ast.CompositeLit); ok && lit.Type == nil {
+		sortMapKeys(lit, typ)
+	}
+}
@@ -0,0 +1,49 @@
+package astpos
+
+import "testing"
+
+func TestSortedMapKeys(t *testing.T) {
+	src := `package astpos
+
+	var a = map[string]int{"b": 2, "a": 1, "c": 3}
+	var b = map[int]string{10: "ten", 0x2: "two", 1: "one", 3: "three"}
+	var c = map[string]int{"b": 2, x: 1}
+	var d = []int{3, 2, 1}
+	var e = map[string]map[string]int{"b": {"z": 1, "a": 2}, "a": {}}
+	var f = []map[int]bool{{2: true, 1: false}}
+	`
+	expected := `package astpos
+
+var a = map[string]int{
+	"a": 1,
+	"b": 2,
+	"c": 3,
+}
+var b = map[int]string{
+	1:   "one",
+	0x2: "two",
+	3:   "three",
+	10:  "ten",
+}
+var c = map[string]int{
+	"b": 2,
+	x:   1,
+}
+var d = []int{3, 2, 1}
+var e = map[string]map[string]int{
+	"a": {},
+	"b": {
+		"a": 2,
+		"z": 1,
+	},
+}
+var f = []map[int]bool{
+	{
+		1: false,
+		2: true,
+	},
+}
+`
+	f, fset := RewritePositions(parseSource(t, src), WithSortedMapKeys())
+	checkResult(t, writeAST(t, f, fset), expected)
+}
@@ -0,0 +1,71 @@
+package astpos
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// Byte range [Start, End) of a node in the formatted source code
+type Range struct {
+	Start, End int
+}
+
+// Byte ranges of the top-level declarations and statements
+// in the formatted source code (see FormatWithSourceMap)
+type SourceMap map[ast.Node]Range
+
+// Same as Format but also returns the byte range of every top-level
+// declaration and every statement of f in the formatted code. The
+// ranges of declarations include their doc comments.
+// With WithImports the import declarations are not mapped, since
+// goimports may add or remove them.
+func FormatWithSourceMap(f *ast.File, opts ...Option) ([]byte, SourceMap, error) {
+	src, err := Format(f, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fset := token.NewFileSet()
+	formatted, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, nil, err
+	}
+	skipImports := newOptions(opts).ProcessImports
+	nodes := mappedNodes(f, skipImports)
+	formattedNodes := mappedNodes(formatted, skipImports)
+	if len(nodes) != len(formattedNodes) {
+		return nil, nil, fmt.Errorf("formatted code has %d declarations and statements, expected %d", len(formattedNodes), len(nodes))
+	}
+
+	file := fset.File(formatted.Pos())
+	sourceMap := make(SourceMap, len(nodes))
+	for i, n := range nodes {
+		fn := formattedNodes[i]
+		sourceMap[n] = Range{
+			Start: file.Offset(nodeStart(fn)),
+			End:   file.Offset(fn.End()),
+		}
+	}
+	return src, sourceMap, nil
+}
+
+// Returns the top-level declarations and all statements
+// of the file in source order
+func mappedNodes(f *ast.File, skipImports bool) []ast.Node {
+	var nodes []ast.Node
+	for _, decl := range f.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && skipImports && gen.Tok == token.IMPORT {
+			continue
+		}
+		nodes = append(nodes, decl)
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if stmt, ok := n.(ast.Stmt); ok {
+				nodes = append(nodes, stmt)
+			}
+			return true
+		})
+	}
+	return nodes
+}
@@ -0,0 +1,43 @@
+package astpos
+
+import (
+	"go/ast"
+	"testing"
+)
+
+func TestFormatWithSourceMap(t *testing.T) {
+	src := `package main
+
+	// f does things
+	func f() {
+		x := 1
+		if x > 0 {
+			x++
+		}
+	}
+
+	var y = 2
+	`
+	f := parseSource(t, src)
+	fn := f.Decls[0].(*ast.FuncDecl)
+	ifStmt := fn.Body.List[1].(*ast.IfStmt)
+
+	out, sourceMap, err := FormatWithSourceMap(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n, expected := range map[ast.Node]string{
+		fn:                  "// f does things\nfunc f() {\n\tx := 1\n\tif x > 0 {\n\t\tx++\n\t}\n}",
+		ifStmt:              "if x > 0 {\n\t\tx++\n\t}",
+		ifStmt.Body.List[0]: "x++",
+		f.Decls[1]:          "var y = 2",
+	} {
+		r, ok := sourceMap[n]
+		if !ok {
+			t.Errorf("%T is missing from the source map", n)
+			continue
+		}
+		checkResult(t, string(out[r.Start:r.End]), expected)
+	}
+}
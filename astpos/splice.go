@@ -0,0 +1,54 @@
+package astpos
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+)
+
+// Appends the declarations to a parsed file and positions only them,
+// behind the original content of the file. This way generated
+// declarations can be added to a hand-written file without changing
+// the layout of the rest of it.
+//
+// A token.File can not grow, so the file is moved to a new, larger
+// token.File at the end of the FileSet, which replaces the original one.
+// All positions of the file are shifted accordingly.
+func Splice(dst *ast.File, dstFset *token.FileSet, decls ...ast.Decl) error {
+	file := dstFset.File(dst.Pos())
+	if file == nil {
+		return errors.New("astpos: the file is not part of the FileSet")
+	}
+	for _, decl := range decls {
+		if isNil(decl) {
+			return errors.New("astpos: cannot splice a nil declaration")
+		}
+	}
+
+	base := dstFset.Base()
+	shiftFile(dst, base-file.Base())
+
+	p := &astPositioner{}
+	p.reset()
+	maxInt := int(^uint(0) >> 1)
+	p.tmpFile = token.NewFileSet().AddFile(file.Name(), base, maxInt-base-1)
+	p.tmpFile.SetLines(file.Lines())
+	p.root = dst
+	p.file = dst
+	p.File = p.tmpFile
+	p.fset = dstFset
+	p.p = base + file.Size()
+
+	for _, decl := range decls {
+		p.blankLine()
+		p.traverse(decl)
+	}
+	p.endLine()
+	dst.FileEnd = p.pc()
+	p.shrinkFile()
+	dstFset.RemoveFile(file)
+
+	dst.Decls = append(dst.Decls, decls...)
+	dst.Comments = append(dst.Comments, p.comments...)
+	return nil
+}
@@ -0,0 +1,67 @@
+package astpos
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestSplice(t *testing.T) {
+	src := `package astpos
+
+// Hand-written
+var x = []int{1,
+	2, 3}
+
+type T struct{ a int } // T
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	method := &ast.FuncDecl{
+		Doc:  &ast.CommentGroup{List: []*ast.Comment{{Text: "// Generated"}}},
+		Recv: &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{ast.NewIdent("t")}, Type: ast.NewIdent("T")}}},
+		Name: ast.NewIdent("A"),
+		Type: &ast.FuncType{Params: &ast.FieldList{}, Results: &ast.FieldList{List: []*ast.Field{{Type: ast.NewIdent("int")}}}},
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.ReturnStmt{Results: []ast.Expr{&ast.SelectorExpr{X: ast.NewIdent("t"), Sel: ast.NewIdent("a")}}},
+		}},
+	}
+	if err := Splice(f, fset, method); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := src + `
+// Generated
+func (t T) A() int {
+	return t.a
+}
+`
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, buf.String(), expected)
+
+	count := 0
+	fset.Iterate(func(*token.File) bool {
+		count++
+		return true
+	})
+	if count != 1 {
+		t.Errorf("FileSet holds %d files instead of 1", count)
+	}
+}
+
+func TestSpliceForeignFile(t *testing.T) {
+	f := parseSource(t, "package astpos\n")
+	if err := Splice(f, token.NewFileSet()); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,124 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// Splits string literals that are wider than the width into a
+// concatenation of literals with one part per line, e.g. generated
+// SQL or JSON constants. The parts break after spaces where possible.
+// Import paths and struct tags are never split.
+func WithMaxStringWidth(width int) Option {
+	return func(o *Options) {
+		o.MaxStringWidth = width
+	}
+}
+
+// Replaces the string literals below the root that exceed the maximum
+// string width by concatenations, which are positioned one part per line.
+// The root itself is kept, since it is returned to the caller.
+func (p *astPositioner) splitStrings(root ast.Node) {
+	width := p.opts.MaxStringWidth
+	astutil.Apply(root, nil, func(c *astutil.Cursor) bool {
+		lit, ok := c.Node().(*ast.BasicLit)
+		if !ok || lit == root || lit.Kind != token.STRING || len(lit.Value) <= width {
+			return true
+		}
+		switch c.Parent().(type) {
+		case *ast.ImportSpec, *ast.Field:
+			return true
+		}
+		parts := splitLiteral(lit.Value, width)
+		if len(parts) < 2 {
+			return true
+		}
+		var concat ast.Expr = &ast.BasicLit{Kind: token.STRING, Value: parts[0]}
+		for _, part := range parts[1:] {
+			concat = &ast.BinaryExpr{X: concat, Op: token.ADD, Y: &ast.BasicLit{Kind: token.STRING, Value: part}}
+		}
+		if p.splitLits == nil {
+			p.splitLits = make(map[ast.Node]bool)
+		}
+		p.splitLits[concat] = true
+		c.Replace(parenBelow(concat, operandPrec(c)))
+		return true
+	})
+}
+
+// Returns the precedence an expression needs at the cursor to keep
+// its meaning without parentheses
+func operandPrec(c *astutil.Cursor) int {
+	switch parent := c.Parent().(type) {
+	case *ast.BinaryExpr:
+		if c.Name() == "X" {
+			return parent.Op.Precedence()
+		}
+		return parent.Op.Precedence() + 1
+	case *ast.UnaryExpr:
+		return token.UnaryPrec
+	case *ast.SelectorExpr, *ast.IndexExpr, *ast.SliceExpr, *ast.TypeAssertExpr:
+		if c.Name() == "X" {
+			return token.HighestPrec
+		}
+	}
+	return token.LowestPrec
+}
+
+// Splits the string literal into literals of at most width characters
+// including their quotes. Escape sequences and characters are never
+// split. Returns nil for raw strings that span several lines.
+func splitLiteral(lit string, width int) []string {
+	quote := lit[:1]
+	content := lit[1 : len(lit)-1]
+	if quote == "`" && strings.Contains(content, "\n") {
+		return nil
+	}
+	limit := max(width-2, 1)
+
+	var parts []string
+	for len(content) > 0 {
+		end, space := 0, 0
+		for end < len(content) {
+			n := tokenLen(content[end:], quote == "`")
+			if end+n > limit && end > 0 {
+				break
+			}
+			end += n
+			if content[end-1] == ' ' {
+				space = end
+			}
+		}
+		// Break after the last space unless the part gets much shorter
+		if end < len(content) && space > end/2 {
+			end = space
+		}
+		parts = append(parts, quote+content[:end]+quote)
+		content = content[end:]
+	}
+	return parts
+}
+
+// Returns the length of the character or escape sequence at the
+// start of the content of a string literal
+func tokenLen(s string, raw bool) int {
+	if raw || s[0] != '\\' || len(s) < 2 {
+		_, n := utf8.DecodeRuneInString(s)
+		return n
+	}
+	switch s[1] {
+	case 'x':
+		return min(4, len(s))
+	case 'u':
+		return min(6, len(s))
+	case 'U':
+		return min(10, len(s))
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		return min(4, len(s))
+	}
+	return 2
+}
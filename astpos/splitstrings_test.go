@@ -0,0 +1,55 @@
+package astpos
+
+import "testing"
+
+func TestMaxStringWidth(t *testing.T) {
+	src := "package astpos\n\n" +
+		"import _ \"example.com/a/very/long/import/path/that/is/never/split\"\n\n" +
+		"type T struct {\n\tA int `json:\"a_field_with_a_long_name,omitempty\" xml:\"a\"`\n}\n\n" +
+		"const query = \"SELECT id, name FROM users WHERE active = 1 ORDER BY name\"\n\n" +
+		"var raw = `a raw string literal that is a little too long`\n\n" +
+		"var esc = \"\\u00e4\\u00f6\\u00fc\\u00e4\\u00f6\\u00fc\\u00e4\\u00f6\\u00fc\"\n\n" +
+		"var short = \"fits\"\n"
+
+	expected := "package astpos\n\n" +
+		"import _ \"example.com/a/very/long/import/path/that/is/never/split\"\n\n" +
+		"type T struct {\n\tA int `json:\"a_field_with_a_long_name,omitempty\" xml:\"a\"`\n}\n\n" +
+		"const query = \"SELECT id, name FROM \" +\n" +
+		"\t\"users WHERE active = \" +\n" +
+		"\t\"1 ORDER BY name\"\n\n" +
+		"var raw = `a raw string literal ` +\n" +
+		"\t`that is a little too ` +\n" +
+		"\t`long`\n" +
+		"var esc = \"\\u00e4\\u00f6\\u00fc\" +\n" +
+		"\t\"\\u00e4\\u00f6\\u00fc\" +\n" +
+		"\t\"\\u00e4\\u00f6\\u00fc\"\n" +
+		"var short = \"fits\"\n"
+
+	// Parts break after a space and never inside an escape sequence
+	f, fset := RewritePositions(parseSource(t, src), WithMaxStringWidth(24))
+	checkResult(t, writeAST(t, f, fset), expected)
+}
+
+func TestMaxStringWidthOperands(t *testing.T) {
+	src := `package astpos
+
+	func f() {
+		s := prefix + "a rather long string literal" + suffix
+		c := "a rather long string literal"[i]
+	}
+	`
+
+	expected := `package astpos
+
+func f() {
+	s := prefix + ("a rather long " +
+		"string literal") + suffix
+	c := ("a rather long " +
+		"string literal")[i]
+}
+`
+
+	// The concatenations keep the meaning of the operands
+	f, fset := RewritePositions(parseSource(t, src), WithMaxStringWidth(20), WithInvariantChecks())
+	checkResult(t, writeAST(t, f, fset), expected)
+}
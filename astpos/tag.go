@@ -0,0 +1,31 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Returns a struct tag of the key/value pairs, e.g. {"json": "name"}
+// becomes `json:"name"`. The keys are sorted so that the tag does not
+// depend on the iteration order of the map. The tag is a raw string
+// unless a value contains a backquote.
+func StructTag(tags map[string]string) *ast.BasicLit {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + ":" + strconv.Quote(tags[key])
+	}
+	tag := strings.Join(pairs, " ")
+	if strings.Contains(tag, "`") {
+		return &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(tag)}
+	}
+	return &ast.BasicLit{Kind: token.STRING, Value: "`" + tag + "`"}
+}
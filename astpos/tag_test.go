@@ -0,0 +1,35 @@
+package astpos
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestStructTag(t *testing.T) {
+	tests := []struct {
+		tags     map[string]string
+		expected string
+	}{
+		{map[string]string{"json": "name,omitempty"}, "`json:\"name,omitempty\"`"},
+		{map[string]string{"yaml": "n", "json": "n", "db": "n"}, "`db:\"n\" json:\"n\" yaml:\"n\"`"},
+		{map[string]string{"doc": "a `b`"}, `"doc:\"a ` + "`b`" + `\""`},
+		{map[string]string{}, "``"},
+	}
+	for _, test := range tests {
+		tag := StructTag(test.tags)
+		if tag.Value != test.expected {
+			t.Errorf("StructTag(%v) = %s, expected %s", test.tags, tag.Value, test.expected)
+		}
+		// The tag is a valid Go string whose keys can be looked up
+		value, err := strconv.Unquote(tag.Value)
+		if err != nil {
+			t.Fatalf("StructTag(%v) is not a valid string: %v", test.tags, err)
+		}
+		for key, want := range test.tags {
+			if got := reflect.StructTag(value).Get(key); got != want {
+				t.Errorf("Key %s of %s = %q, expected %q", key, tag.Value, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package astpos
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+// Same as RewritePositions but checks the file for malformed nodes
+// first, e.g. missing required fields or contradictory tokens, that
+// would otherwise cause broken output or a panic in go/printer.
+// The file is left untouched if an error is returned.
+func RewritePositionsChecked(f *ast.File, opts ...Option) (*ast.File, *token.FileSet, error) {
+	if err := validate(f); err != nil {
+		return nil, nil, err
+	}
+	f, fset := RewritePositions(f, opts...)
+	return f, fset, nil
+}
+
+// Returns an error for every malformed node of the tree
+func validate(root ast.Node) error {
+	var errs []error
+	ast.Walk(validator{errs: &errs}, root)
+	return errors.Join(errs...)
+}
+
+type validator struct {
+	errs *[]error
+
+	// Nodes from the root down to the current node
+	path []ast.Node
+}
+
+func (v validator) Visit(n ast.Node) ast.Visitor {
+	if n == nil || isNil(n) {
+		return nil
+	}
+	v.path = append(v.path[:len(v.path):len(v.path)], n)
+	for _, problem := range checkNode(n) {
+		*v.errs = append(*v.errs, fmt.Errorf("astpos: %s: %s", v.describePath(), problem))
+	}
+	return v
+}
+
+// Returns the path to the current node, e.g. "FuncDecl main > BlockStmt > ExprStmt"
+func (v validator) describePath() string {
+	names := make([]string, len(v.path))
+	for i, n := range v.path {
+		names[i] = strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if !isNil(n.Name) {
+				names[i] += " " + n.Name.Name
+			}
+		case *ast.TypeSpec:
+			if !isNil(n.Name) {
+				names[i] += " " + n.Name.Name
+			}
+		}
+	}
+	return strings.Join(names, " > ")
+}
+
+// Returns the problems of the node itself, not including its children
+func checkNode(n ast.Node) []string {
+	var problems []string
+	required := func(field string, child ast.Node) {
+		if isNil(child) {
+			problems = append(problems, field+" is missing")
+		}
+	}
+	oneOf := func(field string, tok token.Token, valid ...token.Token) {
+		for _, t := range valid {
+			if tok == t {
+				return
+			}
+		}
+		problems = append(problems, fmt.Sprintf("%s %q is not valid here", field, tok))
+	}
+
+	switch n := n.(type) {
+	case *ast.ArrayType:
+		required("Elt", n.Elt)
+	case *ast.AssignStmt:
+		if len(n.Lhs) == 0 || len(n.Rhs) == 0 {
+			problems = append(problems, "Lhs and Rhs must not be empty")
+		}
+		if n.Tok != token.DEFINE && !isAssignOp(n.Tok) {
+			problems = append(problems, fmt.Sprintf("Tok %q is not an assignment", n.Tok))
+		}
+	case *ast.BadDecl, *ast.BadExpr, *ast.BadStmt:
+		problems = append(problems, "placeholder for source that could not be parsed")
+	case *ast.BasicLit:
+		oneOf("Kind", n.Kind, token.INT, token.FLOAT, token.IMAG, token.CHAR, token.STRING)
+		if n.Value == "" {
+			problems = append(problems, "Value is empty")
+		}
+	case *ast.BinaryExpr:
+		required("X", n.X)
+		required("Y", n.Y)
+		if n.Op.Precedence() == token.LowestPrec {
+			problems = append(problems, fmt.Sprintf("Op %q is not a binary operator", n.Op))
+		}
+	case *ast.BranchStmt:
+		oneOf("Tok", n.Tok, token.BREAK, token.CONTINUE, token.GOTO, token.FALLTHROUGH)
+	case *ast.CallExpr:
+		required("Fun", n.Fun)
+	case *ast.ChanType:
+		required("Value", n.Value)
+		problems = append(problems, checkChanDir(n)...)
+	case *ast.DeclStmt:
+		required("Decl", n.Decl)
+	case *ast.DeferStmt:
+		required("Call", n.Call)
+	case *ast.ExprStmt:
+		required("X", n.X)
+	case *ast.Field:
+		required("Type", n.Type)
+	case *ast.File:
+		required("Name", n.Name)
+	case *ast.ForStmt:
+		required("Body", n.Body)
+	case *ast.FuncDecl:
+		required("Name", n.Name)
+		required("Type", n.Type)
+	case *ast.FuncLit:
+		required("Type", n.Type)
+		required("Body", n.Body)
+	case *ast.GenDecl:
+		problems = append(problems, checkGenDecl(n)...)
+	case *ast.GoStmt:
+		required("Call", n.Call)
+	case *ast.Ident:
+		if n.Name == "" {
+			problems = append(problems, "Name is empty")
+		}
+	case *ast.IfStmt:
+		required("Cond", n.Cond)
+		required("Body", n.Body)
+	case *ast.ImportSpec:
+		required("Path", n.Path)
+	case *ast.IncDecStmt:
+		required("X", n.X)
+		oneOf("Tok", n.Tok, token.INC, token.DEC)
+	case *ast.IndexExpr:
+		required("X", n.X)
+		required("Index", n.Index)
+	case *ast.IndexListExpr:
+		required("X", n.X)
+		if len(n.Indices) == 0 {
+			problems = append(problems, "Indices must not be empty")
+		}
+	case *ast.KeyValueExpr:
+		required("Key", n.Key)
+		required("Value", n.Value)
+	case *ast.LabeledStmt:
+		required("Label", n.Label)
+		required("Stmt", n.Stmt)
+	case *ast.MapType:
+		required("Key", n.Key)
+		required("Value", n.Value)
+	case *ast.ParenExpr:
+		required("X", n.X)
+	case *ast.RangeStmt:
+		required("X", n.X)
+		required("Body", n.Body)
+		if !isNil(n.Key) {
+			oneOf("Tok", n.Tok, token.DEFINE, token.ASSIGN)
+		}
+	case *ast.SelectStmt:
+		required("Body", n.Body)
+	case *ast.SelectorExpr:
+		required("X", n.X)
+		required("Sel", n.Sel)
+	case *ast.SendStmt:
+		required("Chan", n.Chan)
+		required("Value", n.Value)
+	case *ast.SliceExpr:
+		required("X", n.X)
+		if n.Slice3 && (isNil(n.High) || isNil(n.Max)) {
+			problems = append(problems, "High and Max are required for a 3-index slice")
+		}
+	case *ast.StarExpr:
+		required("X", n.X)
+	case *ast.SwitchStmt:
+		required("Body", n.Body)
+	case *ast.TypeAssertExpr:
+		required("X", n.X)
+	case *ast.TypeSpec:
+		required("Name", n.Name)
+		required("Type", n.Type)
+	case *ast.TypeSwitchStmt:
+		required("Assign", n.Assign)
+		required("Body", n.Body)
+	case *ast.UnaryExpr:
+		required("X", n.X)
+		oneOf("Op", n.Op, token.ADD, token.SUB, token.NOT, token.XOR, token.MUL, token.AND, token.ARROW, token.TILDE)
+	case *ast.ValueSpec:
+		if len(n.Names) == 0 {
+			problems = append(problems, "Names must not be empty")
+		}
+	}
+	return problems
+}
+
+// The positioner derives the place of the arrow from the positions
+// of the channel type, so they must not contradict its direction
+func checkChanDir(n *ast.ChanType) []string {
+	switch n.Dir {
+	case ast.SEND | ast.RECV:
+		if n.Arrow.IsValid() {
+			return []string{"Arrow is set for a bidirectional channel"}
+		}
+	case ast.RECV:
+		if n.Arrow.IsValid() && n.Arrow != n.Begin {
+			return []string{"Arrow of a receive-only channel does not equal Begin"}
+		}
+	case ast.SEND:
+		if n.Arrow.IsValid() && n.Arrow <= n.Begin {
+			return []string{"Arrow of a send-only channel does not follow Begin"}
+		}
+	default:
+		return []string{fmt.Sprintf("Dir %d is not a channel direction", n.Dir)}
+	}
+	return nil
+}
+
+func checkGenDecl(n *ast.GenDecl) []string {
+	var spec reflect.Type
+	switch n.Tok {
+	case token.IMPORT:
+		spec = reflect.TypeOf(&ast.ImportSpec{})
+	case token.CONST, token.VAR:
+		spec = reflect.TypeOf(&ast.ValueSpec{})
+	case token.TYPE:
+		spec = reflect.TypeOf(&ast.TypeSpec{})
+	default:
+		return []string{fmt.Sprintf("Tok %q is not a declaration keyword", n.Tok)}
+	}
+
+	var problems []string
+	for i, s := range n.Specs {
+		if isNil(s) {
+			problems = append(problems, fmt.Sprintf("Specs[%d] is missing", i))
+		} else if reflect.TypeOf(s) != spec {
+			problems = append(problems, fmt.Sprintf("Specs[%d] is a %T in a %s declaration", i, s, n.Tok))
+		}
+	}
+	return problems
+}
+
+func isAssignOp(t token.Token) bool {
+	return t == token.ASSIGN || (t >= token.ADD_ASSIGN && t <= token.AND_NOT_ASSIGN)
+}
@@ -0,0 +1,63 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestRewritePositionsChecked(t *testing.T) {
+	f, fset, err := RewritePositionsChecked(parseSource(t, sampleSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeAST(t, f, fset)
+}
+
+func TestRewritePositionsCheckedReportsMalformedNodes(t *testing.T) {
+	f := parseSource(t, `package astpos
+
+	func main() {
+		var c chan<- int
+		x := 1
+		x++
+	}
+	`)
+
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	spec := body.List[0].(*ast.DeclStmt).Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	chanType := spec.Type.(*ast.ChanType)
+	chanType.Arrow = chanType.Begin
+	body.List[1].(*ast.AssignStmt).Rhs = []ast.Expr{&ast.BinaryExpr{X: ast.NewIdent("x"), Op: token.ADD}}
+	body.List[2].(*ast.IncDecStmt).Tok = token.MUL
+
+	_, _, err := RewritePositionsChecked(f)
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, want := range []string{
+		"FuncDecl main > BlockStmt > DeclStmt > GenDecl > ValueSpec > ChanType: Arrow of a send-only channel does not follow Begin",
+		"FuncDecl main > BlockStmt > AssignStmt > BinaryExpr: Y is missing",
+		`FuncDecl main > BlockStmt > IncDecStmt: Tok "*" is not valid here`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing error %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestRewritePositionsCheckedReportsBadNodes(t *testing.T) {
+	f := parseSource(t, `package astpos
+
+	var x = 1
+	`)
+	spec := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	spec.Values[0] = &ast.BadExpr{}
+
+	_, _, err := RewritePositionsChecked(f)
+	want := "GenDecl > ValueSpec > BadExpr: placeholder for source that could not be parsed"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("missing error %q in:\n%v", want, err)
+	}
+}
@@ -0,0 +1,168 @@
+package astpos
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+)
+
+// Rewrites the positions of a copy of the given file, formats it
+// with go/format, parses the result again and type-checks it with
+// go/types. Returns the errors of all three steps that the rewrite
+// introduced, e.g. the type errors of code that was printed in a
+// broken way. Type errors that the given AST has on its own are not
+// reported. The given AST is not modified.
+//
+// Imports are resolved from the sources in GOROOT. Packages that
+// can not be found are replaced by empty placeholders, so the type
+// errors of references into them are not introduced by the rewrite.
+func VerifyTypeChecks(f *ast.File) error {
+	// The errors of the tree itself, which is type-checked
+	// with the new positions
+	rewritten, fset := RewritePositions(Clone(f))
+	imp := permissiveImporter{importer.ForCompiler(token.NewFileSet(), "source", nil)}
+	known := make(map[string]int)
+	for _, err := range typeCheck(rewritten, fset, imp) {
+		known[err.Msg]++
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, rewritten); err != nil {
+		return err
+	}
+
+	fset = token.NewFileSet()
+	parsed, err := parser.ParseFile(fset, "x.go", buf.Bytes(), parser.SkipObjectResolution)
+	if err != nil {
+		return err
+	}
+
+	var introduced []error
+	for _, err := range typeCheck(parsed, fset, imp) {
+		if known[err.Msg] > 0 {
+			known[err.Msg]--
+			continue
+		}
+		introduced = append(introduced, err)
+	}
+	return errors.Join(introduced...)
+}
+
+// Returns the type errors of the file
+func typeCheck(f *ast.File, fset *token.FileSet, imp types.Importer) []types.Error {
+	var typeErrors []types.Error
+	conf := types.Config{
+		Importer: imp,
+		Error: func(err error) {
+			var typeErr types.Error
+			if errors.As(err, &typeErr) {
+				typeErrors = append(typeErrors, typeErr)
+			}
+		},
+	}
+	conf.Check(f.Name.Name, fset, []*ast.File{f}, nil)
+	return typeErrors
+}
+
+// Checks the positions of all nodes of the file, e.g. after they
+// were rewritten. Reports nodes that have no position, end before
+// they start, lie outside of the FileSet, outside of their parent
+// node or overlap their previous sibling.
+// Comments are not checked.
+func Verify(f *ast.File, fset *token.FileSet) error {
+	v := verifier{fset: fset}
+	ast.Inspect(f, v.visit)
+	return errors.Join(v.errs...)
+}
+
+type verifier struct {
+	fset *token.FileSet
+	errs []error
+
+	// Parents of the current node
+	stack []verifyFrame
+}
+
+type verifyFrame struct {
+	node     ast.Node
+	pos, end token.Pos
+
+	// End of the last child that was visited
+	lastEnd token.Pos
+}
+
+func (v *verifier) visit(n ast.Node) bool {
+	if n == nil {
+		v.stack = v.stack[:len(v.stack)-1]
+		return false
+	}
+	if isNil(n) {
+		return false
+	}
+	switch n.(type) {
+	case *ast.CommentGroup, *ast.Comment:
+		return false
+	}
+
+	pos, end := n.Pos(), n.End()
+	v.check(n, pos, end)
+	v.stack = append(v.stack, verifyFrame{node: n, pos: pos, end: end})
+	return true
+}
+
+func (v *verifier) check(n ast.Node, pos, end token.Pos) {
+	if !pos.IsValid() {
+		v.report(n, pos, "has no position")
+		return
+	}
+	if end < pos {
+		v.report(n, pos, "ends before it starts")
+	}
+	if v.fset.File(pos) == nil || v.fset.File(end) != v.fset.File(pos) {
+		v.report(n, pos, "is not within a file of the FileSet")
+	}
+	if len(v.stack) == 0 {
+		return
+	}
+
+	parent := &v.stack[len(v.stack)-1]
+	if !parent.pos.IsValid() || parent.end < parent.pos {
+		return
+	}
+	if pos < parent.pos || end > parent.end {
+		v.report(n, pos, fmt.Sprintf("is outside of its parent %T", parent.node))
+	}
+	// The func keyword of a declaration is part of its
+	// type but comes before the receiver and the name
+	if decl, ok := parent.node.(*ast.FuncDecl); !ok || n != decl.Type {
+		if pos < parent.lastEnd {
+			v.report(n, pos, "overlaps its previous sibling")
+		}
+	}
+	parent.lastEnd = max(parent.lastEnd, end)
+}
+
+func (v *verifier) report(n ast.Node, pos token.Pos, msg string) {
+	v.errs = append(v.errs, fmt.Errorf("astpos: %s: %T %s", v.fset.Position(pos), n, msg))
+}
+
+// Falls back to an empty package for imports that can not be resolved
+type permissiveImporter struct {
+	types.Importer
+}
+
+func (i permissiveImporter) Import(importPath string) (*types.Package, error) {
+	pkg, err := i.Importer.Import(importPath)
+	if err != nil {
+		pkg = types.NewPackage(importPath, path.Base(importPath))
+		pkg.MarkComplete()
+	}
+	return pkg, nil
+}
@@ -0,0 +1,103 @@
+package astpos
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestVerifyTypeChecks(t *testing.T) {
+	f := parseSource(t, sampleSource)
+	if err := VerifyTypeChecks(f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyTypeChecksReportsErrors(t *testing.T) {
+	// The name of the identifier prints as an expression
+	// that does not type-check
+	f := &ast.File{
+		Name: ast.NewIdent("broken"),
+		Decls: []ast.Decl{
+			&ast.GenDecl{Tok: token.VAR, Specs: []ast.Spec{&ast.ValueSpec{
+				Names:  []*ast.Ident{ast.NewIdent("s")},
+				Values: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: `"s"`}},
+			}}},
+			&ast.GenDecl{Tok: token.VAR, Specs: []ast.Spec{&ast.ValueSpec{
+				Names:  []*ast.Ident{ast.NewIdent("x")},
+				Values: []ast.Expr{ast.NewIdent("s + 1")},
+			}}},
+		},
+	}
+	ClearPositions(f)
+	err := VerifyTypeChecks(f)
+	if err == nil {
+		t.Fatal("expected a type error")
+	}
+	if !strings.Contains(err.Error(), "mismatched types") {
+		t.Errorf("expected the introduced type error, got:\n%v", err)
+	}
+	if f.Decls[0].Pos().IsValid() {
+		t.Error("the given file was rewritten")
+	}
+}
+
+func TestVerifyTypeChecksIgnoresExistingErrors(t *testing.T) {
+	src := `package broken
+
+	import "example.com/unknown"
+
+	var x int = "not an int"
+
+	var y = unknown.Value
+	`
+	if err := VerifyTypeChecks(parseSource(t, src)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", sampleSource, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(f, fset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyReportsErrors(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "x.go", sampleSource, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing paren in front of the called function
+	var call *ast.CallExpr
+	ast.Inspect(f, func(n ast.Node) bool {
+		if c, ok := n.(*ast.CallExpr); ok && call == nil && len(c.Args) > 0 {
+			call = c
+		}
+		return call == nil
+	})
+	call.Rparen = call.Fun.Pos() - 2
+	// A new node without a position
+	f.Decls = append(f.Decls, &ast.GenDecl{
+		Tok:   token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{Names: []*ast.Ident{ast.NewIdent("x")}, Type: ast.NewIdent("int")}},
+	})
+
+	err = Verify(f, fset)
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, want := range []string{"*ast.CallExpr ends before it starts", "*ast.GenDecl has no position"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing error %q in:\n%v", want, err)
+		}
+	}
+}
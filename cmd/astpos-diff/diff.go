@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lines of unchanged context around each change
+const contextLines = 3
+
+// A line of the diff, prefixed with ' ', '-' or '+'
+type diffLine struct {
+	kind byte
+	text string
+
+	// Line numbers in the old and new text, counted from 0
+	oldLine, newLine int
+}
+
+// Returns the unified diff from a to b, empty if both are equal
+func unifiedDiff(aName, bName, a, b string) string {
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	for start := 0; start < len(lines); {
+		// Find the next change
+		for start < len(lines) && lines[start].kind == ' ' {
+			start++
+		}
+		if start == len(lines) {
+			break
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+
+		// Extend the hunk while the changes are close together
+		first := max(start-contextLines, 0)
+		end := start
+		for unchanged := 0; end < len(lines) && unchanged <= 2*contextLines; end++ {
+			if lines[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		last := end
+		for last > start && lines[last-1].kind == ' ' {
+			last--
+		}
+		last = min(last+contextLines, len(lines))
+
+		hunk := lines[first:last]
+		var oldCount, newCount int
+		for _, l := range hunk {
+			if l.kind != '+' {
+				oldCount++
+			}
+			if l.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", hunk[0].oldLine+1, oldCount, hunk[0].newLine+1, newCount)
+		for _, l := range hunk {
+			out.WriteByte(l.kind)
+			out.WriteString(l.text)
+			out.WriteByte('\n')
+		}
+		start = last
+	}
+	return out.String()
+}
+
+// Aligns the lines of a and b along their longest common subsequence
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i, j})
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j], i, j})
+			j++
+		}
+	}
+	return lines
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	b := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\n"
+	expected := `--- a
++++ b
+@@ -2,9 +2,10 @@
+ b
+ c
+ d
+-e
++E
+ f
+ g
+ h
+ i
+ j
++k
+`
+	if d := unifiedDiff("a", "b", a, b); d != expected {
+		t.Errorf("Unexpected diff:\n%s", d)
+	}
+	if d := unifiedDiff("a", "b", a, a); d != "" {
+		t.Errorf("Equal texts have a diff:\n%s", d)
+	}
+}
+
+func TestDiffFile(t *testing.T) {
+	src := "package main\n\nvar x = []int{1, 2, 3, 4}\n"
+	expected := `--- x.go (gofmt)
++++ x.go (astpos)
+@@ -1,3 +1,5 @@
+ package main
+ 
+-var x = []int{1, 2, 3, 4}
++var x = []int{
++	1, 2, 3, 4,
++}
+`
+	d, err := diffFile("x.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != expected {
+		t.Errorf("Unexpected diff:\n%s", d)
+	}
+}
@@ -0,0 +1,85 @@
+// Command astpos-diff shows how the output of astpos differs from
+// gofmt. It parses a Go file, clears its positions, rewrites and
+// formats it with astpos and prints a unified diff against the
+// gofmt output of the original, which makes misformatting easy to
+// report.
+//
+// Usage:
+//
+//	astpos-diff [-columns] [-gofumpt] file ...
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+
+	"github.com/snonky/astpos/astpos"
+)
+
+var (
+	columns = flag.Bool("columns", false, "rewrite with meaningful columns (see astpos.WithColumns)")
+	gofumpt = flag.Bool("gofumpt", false, "follow the conventions of gofumpt (see astpos.WithGofumpt)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: astpos-diff [flags] file ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var opts []astpos.Option
+	if *columns {
+		opts = append(opts, astpos.WithColumns())
+	}
+	if *gofumpt {
+		opts = append(opts, astpos.WithGofumpt())
+	}
+	for _, path := range flag.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fail(err)
+		}
+		d, err := diffFile(path, src, opts...)
+		if err != nil {
+			fail(err)
+		}
+		os.Stdout.WriteString(d)
+	}
+}
+
+// Returns the unified diff from the gofmt output of the source
+// to its astpos output, empty if both are equal
+func diffFile(filename string, src []byte, opts ...astpos.Option) (string, error) {
+	gofmt, err := format.Source(src)
+	if err != nil {
+		return "", err
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	// The comments keep their places without the positions
+	anchors := astpos.ReanchorComments(f, fset)
+	astpos.ClearPositions(f)
+	out, err := astpos.Format(f, append(opts, anchors, astpos.WithFilename(filename))...)
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff(filename+" (gofmt)", filename+" (astpos)", string(gofmt), string(out)), nil
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "astpos-diff:", err)
+	os.Exit(1)
+}
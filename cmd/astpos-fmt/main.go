@@ -0,0 +1,103 @@
+// Command astpos-fmt clears all positions of Go files, rewrites them
+// with astpos and prints the result. It normalizes generated files
+// with broken positions and serves as a smoke test of astpos.
+//
+// Usage:
+//
+//	astpos-fmt [-w] [-columns] [-gofumpt] [file ...]
+//
+// Without files the source is read from standard input.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+
+	"github.com/snonky/astpos/astpos"
+)
+
+var (
+	write   = flag.Bool("w", false, "write the result to the file instead of standard output")
+	columns = flag.Bool("columns", false, "rewrite with meaningful columns (see astpos.WithColumns)")
+	gofumpt = flag.Bool("gofumpt", false, "follow the conventions of gofumpt (see astpos.WithGofumpt)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: astpos-fmt [flags] [file ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fail(fmt.Errorf("-w requires files"))
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fail(err)
+		}
+		out, err := format("<stdin>", src, options()...)
+		if err != nil {
+			fail(err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	for _, path := range flag.Args() {
+		if err := formatFile(path, options()...); err != nil {
+			fail(err)
+		}
+	}
+}
+
+func options() []astpos.Option {
+	var opts []astpos.Option
+	if *columns {
+		opts = append(opts, astpos.WithColumns())
+	}
+	if *gofumpt {
+		opts = append(opts, astpos.WithGofumpt())
+	}
+	return opts
+}
+
+func formatFile(path string, opts ...astpos.Option) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, err := format(path, src, opts...)
+	if err != nil {
+		return err
+	}
+	if *write {
+		return os.WriteFile(path, out, 0o644)
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// Parses the source, clears its positions and formats it with astpos.
+// The comments are anchored to their nodes before the positions are
+// cleared, so they keep their places.
+func format(filename string, src []byte, opts ...astpos.Option) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	anchors := astpos.ReanchorComments(f, fset)
+	astpos.ClearPositions(f)
+	return astpos.Format(f, append(opts, anchors, astpos.WithFilename(filename))...)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "astpos-fmt:", err)
+	os.Exit(1)
+}
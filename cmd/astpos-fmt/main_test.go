@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	src := `package main
+
+// f does things
+func f()   {
+	x :=   1 // one
+
+	// after a blank line
+	println(x)
+}
+`
+	expected := `package main
+
+// f does things
+func f() {
+	x := 1 // one
+
+	// after a blank line
+	println(x)
+}
+`
+	out, err := format("x.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != expected {
+		t.Errorf("Unexpected output:\n%s", out)
+	}
+}
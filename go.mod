@@ -2,7 +2,10 @@ module github.com/snonky/astpos
 
 go 1.23.5
 
-require golang.org/x/tools v0.30.0
+require (
+	github.com/dave/dst v0.27.3
+	golang.org/x/tools v0.30.0
+)
 
 require (
 	golang.org/x/mod v0.23.0 // indirect